@@ -0,0 +1,42 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"errors"
+	"io"
+)
+
+//响应内容超过MaxBodySize且未设置TruncateBody时返回的错误,可通过errors.Is判断
+var ErrBodyTooLarge = errors.New("response body too large")
+
+//限制了长度的原始响应内容,关闭时关闭原始的响应内容
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+//本次抓取的响应内容最大长度及超过时是否截断,opts中的MaxBodySize不为0时优先于GatherStruct中的设置
+//返回的maxSize小于等于0表示不限制
+func (g *GatherStruct) bodyLimit(opts *RequestOptions) (maxSize int64, truncate bool) {
+	maxSize, truncate = g.MaxBodySize, g.TruncateBody
+	if opts != nil {
+		if opts.MaxBodySize != 0 {
+			maxSize = opts.MaxBodySize
+		}
+		truncate = truncate || opts.TruncateBody
+	}
+	return maxSize, truncate
+}
+
+//给缓存池中的所有采集器设置同样的响应内容最大长度,truncate为true时超过的部分直接丢弃,不返回错误
+func (p *Pool) SetMaxBodySize(maxSize int64, truncate bool) {
+	for _, ga := range p.pool {
+		ga.MaxBodySize = maxSize
+		ga.TruncateBody = truncate
+	}
+}
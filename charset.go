@@ -0,0 +1,137 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+//只在网页开头的这部分内容中查找<meta charset>及XML声明,与浏览器的做法一致
+const charsetSniffLen = 4096
+
+var (
+	//<meta charset="gbk">
+	metaCharsetReg = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-zA-Z0-9_\-:.]+)`)
+	//<?xml version="1.0" encoding="gb2312"?>
+	xmlEncodingReg = regexp.MustCompile(`(?i)<\?xml[^>]+encoding\s*=\s*["']\s*([a-zA-Z0-9_\-:.]+)`)
+)
+
+/*
+判断网页编码,返回规范化后的编码名称,如"utf-8","gbk","gb18030","big5","shift_jis"
+依次从BOM,Content-Type响应头,XML声明,<meta charset>及http-equiv中查找
+都没有声明时,合法的UTF-8内容认为是"utf-8",否则无法判断,返回空字符串
+
+例:
+charset := gather.DetectCharset(resp.Body, resp.Header.Get("Content-Type"))
+*/
+func DetectCharset(data []byte, contentType string) string {
+	//BOM优先级最高
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+	//Content-Type: text/html; charset=gbk
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			if name := normalizeCharset(params["charset"]); name != "" {
+				return name
+			}
+		}
+	}
+	head := data
+	if len(head) > charsetSniffLen {
+		head = head[:charsetSniffLen]
+	}
+	if m := xmlEncodingReg.FindSubmatch(head); m != nil {
+		if name := normalizeCharset(string(m[1])); name != "" {
+			return name
+		}
+	}
+	//同时兼容<meta charset="gbk">与<meta http-equiv="Content-Type" content="text/html; charset=gbk">
+	if m := metaCharsetReg.FindSubmatch(head); m != nil {
+		if name := normalizeCharset(string(m[1])); name != "" {
+			return name
+		}
+	}
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+	return ""
+}
+
+//把各种写法的编码名称统一成WHATWG规范中的名称,无法识别时返回空
+func normalizeCharset(name string) string {
+	name = strings.TrimSpace(strings.Trim(name, `"'`))
+	if name == "" {
+		return ""
+	}
+	e, err := htmlindex.Get(name)
+	if err != nil {
+		return ""
+	}
+	canonical, err := htmlindex.Name(e)
+	if err != nil {
+		return ""
+	}
+	return canonical
+}
+
+/*
+把指定编码的内容转换为UTF-8,charset为空时自动调用DetectCharset判断
+原本就是UTF-8的内容会去掉BOM后原样返回,charset为空且无法判断编码时原样返回
+
+例:
+utf8Body, err := gather.ConvertToUTF8(resp.Body, "gbk")
+*/
+func ConvertToUTF8(data []byte, charset string) ([]byte, error) {
+	if charset == "" {
+		if charset = DetectCharset(data, ""); charset == "" {
+			return data, nil
+		}
+	}
+	var e encoding.Encoding
+	if name := normalizeCharset(charset); name == "utf-8" {
+		return bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}), nil
+	} else if name == "" {
+		e = unicode.UTF8
+	} else {
+		e, _ = htmlindex.Get(name)
+	}
+	//BOMOverride可以在内容带有BOM时正确处理UTF-16
+	out, _, err := transform.Bytes(unicode.BOMOverride(e.NewDecoder()), data)
+	return out, err
+}
+
+//只对文本类的内容进行编码转换,图片,压缩包等二进制内容及没有Content-Type的内容原样保留
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, s := range []string{"html", "xml", "json", "javascript"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,361 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Netscape cookies.txt格式的文件头,curl,wget均使用此格式
+const netscapeCookieHeader = "# Netscape HTTP Cookie File\n# This file was generated by gather. Edit at your own risk.\n\n"
+
+//curl对HttpOnly的cookie在域名前加此前缀
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+//JSON格式中保存的一条cookie
+type savedCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Session  bool       `json:"session"`
+	HostOnly bool       `json:"hostOnly"`
+	Secure   bool       `json:"secure"`
+	HttpOnly bool       `json:"httpOnly"`
+	SameSite string     `json:"sameSite,omitempty"`
+	Creation time.Time  `json:"creation"`
+}
+
+/*
+返回jar中所有未过期的cookie,包括会话cookie
+Domain以"."开头的表示同时发给子域名,否则只发给该主机
+*/
+func (j *WebCookieJar) AllCookies() []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, e := range j.entries() {
+		c := &http.Cookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			Path:     e.Path,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: e.SameSite,
+		}
+		if !e.HostOnly {
+			c.Domain = "." + e.Domain
+		}
+		if e.Persistent {
+			c.Expires = e.Expires
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies
+}
+
+//按域名,路径,创建顺序排好序的全部未过期cookie,保存到文件时顺序固定,便于比较
+func (j *WebCookieJar) entries() []cookieEntry {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	now := time.Now()
+	var all []cookieEntry
+	for _, submap := range j.cookies {
+		for _, e := range submap {
+			if e.Persistent && !e.Expires.After(now) {
+				continue
+			}
+			all = append(all, e)
+		}
+	}
+	sort.Slice(all, func(i, k int) bool {
+		if all[i].Domain != all[k].Domain {
+			return all[i].Domain < all[k].Domain
+		}
+		if all[i].Path != all[k].Path {
+			return all[i].Path < all[k].Path
+		}
+		return all[i].seqNum < all[k].seqNum
+	})
+	return all
+}
+
+//把从文件中读出的cookie放入jar,已过期的直接丢弃,同一条cookie以文件中的为准
+func (j *WebCookieJar) addEntries(entries []cookieEntry) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	now := time.Now()
+	for _, e := range entries {
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		if e.Path == "" {
+			e.Path = "/"
+		}
+		if e.Creation.IsZero() {
+			e.Creation = now
+		}
+		e.LastAccess = now
+		e.seqNum = j.nextSeqNum
+		j.nextSeqNum++
+		key := jarKey(e.Domain)
+		if j.cookies[key] == nil {
+			j.cookies[key] = make(map[string]cookieEntry)
+		}
+		j.cookies[key][e.id()] = e
+		j.println("载入cookie:", e.Domain, e.Path, e.Name)
+	}
+}
+
+//以JSON格式输出jar中的全部cookie
+func (j *WebCookieJar) WriteJSON(w io.Writer) error {
+	saved := []savedCookie{}
+	for _, e := range j.entries() {
+		s := savedCookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			Path:     e.Path,
+			Session:  !e.Persistent,
+			HostOnly: e.HostOnly,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: sameSiteName(e.SameSite),
+			Creation: e.Creation,
+		}
+		if e.Persistent {
+			expires := e.Expires
+			s.Expires = &expires
+		}
+		saved = append(saved, s)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(saved)
+}
+
+//读取WriteJSON输出的cookie,与jar中已有的cookie合并
+func (j *WebCookieJar) ReadJSON(r io.Reader) error {
+	var saved []savedCookie
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+	var entries []cookieEntry
+	for _, s := range saved {
+		domain, err := canonicalHost(strings.TrimPrefix(s.Domain, "."))
+		if err != nil || s.Name == "" {
+			continue
+		}
+		e := cookieEntry{
+			Name:       s.Name,
+			Value:      s.Value,
+			Domain:     domain,
+			Path:       s.Path,
+			SameSite:   parseSameSite(s.SameSite),
+			Secure:     s.Secure,
+			HttpOnly:   s.HttpOnly,
+			Persistent: !s.Session && s.Expires != nil,
+			HostOnly:   s.HostOnly,
+			Expires:    endOfTime,
+			Creation:   s.Creation,
+		}
+		if e.Persistent {
+			e.Expires = *s.Expires
+		}
+		entries = append(entries, e)
+	}
+	j.addEntries(entries)
+	return nil
+}
+
+//以Netscape cookies.txt格式输出jar中的全部cookie,会话cookie的过期时间记为0
+func (j *WebCookieJar) WriteNetscape(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(netscapeCookieHeader)
+	for _, e := range j.entries() {
+		domain := e.Domain
+		includeSubdomains := "FALSE"
+		if !e.HostOnly {
+			domain = "." + domain
+			includeSubdomains = "TRUE"
+		}
+		if e.HttpOnly {
+			domain = netscapeHttpOnlyPrefix + domain
+		}
+		secure := "FALSE"
+		if e.Secure {
+			secure = "TRUE"
+		}
+		var expires int64
+		if e.Persistent {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, includeSubdomains, e.Path, secure, expires, e.Name, e.Value)
+	}
+	return bw.Flush()
+}
+
+//读取Netscape cookies.txt格式的cookie,与jar中已有的cookie合并,可直接读取curl,wget及浏览器插件导出的文件
+func (j *WebCookieJar) ReadNetscape(r io.Reader) error {
+	var entries []cookieEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, netscapeHttpOnlyPrefix) {
+			httpOnly = true
+			line = line[len(netscapeHttpOnlyPrefix):]
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		//值为空时,有的工具会省略最后一个制表符
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("cookies.txt第%d行格式错误:%q", lineNum, line)
+		}
+		domain, err := canonicalHost(strings.TrimPrefix(fields[0], "."))
+		if err != nil {
+			return fmt.Errorf("cookies.txt第%d行域名错误:%v", lineNum, err)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookies.txt第%d行过期时间错误:%v", lineNum, err)
+		}
+		e := cookieEntry{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+		}
+		if expires == 0 {
+			e.Expires = endOfTime
+		} else {
+			e.Expires = time.Unix(expires, 0)
+			e.Persistent = true
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	j.addEntries(entries)
+	return nil
+}
+
+/*
+把jar中的全部cookie保存到文件,以便程序重启后恢复登录状态
+文件扩展名为.txt时保存为Netscape cookies.txt格式,可直接给curl,wget使用,其它扩展名保存为JSON格式
+先写入临时文件再改名,保存过程中程序退出也不会损坏原有文件
+
+例:
+ga.J.SaveCookies("cookies.json")
+ga.J.SaveCookies("cookies.txt")
+*/
+func (j *WebCookieJar) SaveCookies(fileName string) error {
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(fileName), ".txt") {
+		err = j.WriteNetscape(&buf)
+	} else {
+		err = j.WriteJSON(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(buf.Bytes()); err == nil {
+		err = tmp.Chmod(0600) //cookies中往往含有登录凭证
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+/*
+从文件中载入cookie,与jar中已有的cookie合并,根据文件内容自动识别JSON及Netscape cookies.txt格式
+已过期的cookie会被丢弃
+
+例:
+ga := NewGather("chrome", false)
+err := ga.J.LoadCookies("cookies.json")
+*/
+func (j *WebCookieJar) LoadCookies(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return j.ReadJSON(bytes.NewReader(data))
+	}
+	return j.ReadNetscape(bytes.NewReader(data))
+}
+
+//保存cookies到文件,用法与WebCookieJar.SaveCookies相同
+func (g *GatherStruct) SaveCookies(fileName string) error {
+	return g.J.SaveCookies(fileName)
+}
+
+//从文件中载入cookies,用法与WebCookieJar.LoadCookies相同
+func (g *GatherStruct) LoadCookies(fileName string) error {
+	return g.J.LoadCookies(fileName)
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+//没有SameSite属性时为0,与http.Cookie一致,无法识别的值与标准库一样按SameSiteDefaultMode处理
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "":
+		return 0
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
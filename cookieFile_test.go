@@ -0,0 +1,147 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+//各种属性组合的cookie
+func newTestJar() *WebCookieJar {
+	future := time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)
+	j := NewWebCookieJar(false)
+	setCookies(j, "https://www.example.com/a/b",
+		"host=1",
+		"domain=2; Domain=example.com; Path=/",
+		"secure=3; Secure; Path=/a",
+		"httponly=4; HttpOnly; Domain=.example.com; Expires="+future,
+		"lax=5; SameSite=Lax; Max-Age=3600",
+		"empty=",
+	)
+	setCookies(j, "http://127.0.0.1:8080/", "ip=6")
+	return j
+}
+
+//用于比较的cookie描述,saveSameSite为false时忽略SameSite(Netscape格式不保存)
+func describeCookies(j *WebCookieJar, saveSameSite bool) string {
+	var lines []string
+	for _, c := range j.AllCookies() {
+		expires := int64(0)
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		sameSite := c.SameSite
+		if !saveSameSite {
+			sameSite = 0
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s domain=%s path=%s secure=%v httponly=%v expires=%d samesite=%d",
+			c.Name, c.Value, c.Domain, c.Path, c.Secure, c.HttpOnly, expires, sameSite))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCookieFileRoundTrip(t *testing.T) {
+	j := newTestJar()
+	for _, format := range []string{"json", "netscape"} {
+		var buf bytes.Buffer
+		k := NewWebCookieJar(false)
+		var err error
+		if format == "json" {
+			if err = j.WriteJSON(&buf); err == nil {
+				err = k.ReadJSON(&buf)
+			}
+		} else {
+			if err = j.WriteNetscape(&buf); err == nil {
+				err = k.ReadNetscape(&buf)
+			}
+		}
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		saveSameSite := format == "json"
+		if want, got := describeCookies(j, saveSameSite), describeCookies(k, saveSameSite); got != want {
+			t.Fatalf("%s: 读回的cookie与保存的不同\n期望\n%s\n实际\n%s", format, want, got)
+		}
+		//cookies.txt中没有创建时间,路径长度相同的cookie发送顺序可能不同,只比较发送了哪些
+		sent := func(j *WebCookieJar, u string) string {
+			s := strings.Fields(sentCookies(j, u))
+			if format == "netscape" {
+				sort.Strings(s)
+			}
+			return strings.Join(s, " ")
+		}
+		for _, u := range []string{"https://www.example.com/a/b", "http://www.example.com/", "http://img.example.com/", "http://127.0.0.1:8080/"} {
+			if want, got := sent(j, u), sent(k, u); got != want {
+				t.Fatalf("%s: 请求%s时期望发送%q, 实际%q", format, u, want, got)
+			}
+		}
+	}
+}
+
+func TestCookieFileNetscapeFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newTestJar().WriteNetscape(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	for _, want := range []string{
+		"# Netscape HTTP Cookie File\n",
+		"#HttpOnly_.example.com\tTRUE\t/a\tFALSE\t",
+		"www.example.com\tFALSE\t/a\tTRUE\t0\tsecure\t3\n",
+		".example.com\tTRUE\t/\tFALSE\t0\tdomain\t2\n",
+		"127.0.0.1\tFALSE\t/\tFALSE\t0\tip\t6\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("输出中没有%q:\n%s", want, text)
+		}
+	}
+
+	//curl导出的文件,包括注释,空行,#HttpOnly_前缀,值为空时省略最后一个制表符
+	expires := time.Now().Add(time.Hour).Unix()
+	curl := fmt.Sprintf("# Netscape HTTP Cookie File\n# https://curl.se/docs/http-cookies.html\n\n"+
+		"#HttpOnly_.example.com\tTRUE\t/\tTRUE\t%d\tsid\tabc\r\n"+
+		"www.example.com\tFALSE\t/\tFALSE\t0\tempty\n"+
+		"www.example.com\tFALSE\t/\tFALSE\t1\texpired\tx\n", expires)
+	j := NewWebCookieJar(false)
+	if err := j.ReadNetscape(strings.NewReader(curl)); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("sid=abc domain=.example.com path=/ secure=true httponly=true expires=%d samesite=0\n"+
+		"empty= domain=www.example.com path=/ secure=false httponly=false expires=0 samesite=0", expires)
+	if got := describeCookies(j, false); got != want {
+		t.Fatalf("读取curl的cookies.txt有误\n期望\n%s\n实际\n%s", want, got)
+	}
+	if err := j.ReadNetscape(strings.NewReader("www.example.com\tFALSE\t/\n")); err == nil {
+		t.Fatal("格式错误的行应返回错误")
+	}
+}
+
+//按扩展名保存为不同格式,载入时自动识别
+func TestSaveLoadCookies(t *testing.T) {
+	j := newTestJar()
+	dir := t.TempDir()
+	for _, name := range []string{"cookies.json", "cookies.txt"} {
+		fileName := filepath.Join(dir, name)
+		if err := j.SaveCookies(fileName); err != nil {
+			t.Fatal(err)
+		}
+		k := NewWebCookieJar(false)
+		if err := k.LoadCookies(fileName); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := describeCookies(j, false), describeCookies(k, false); got != want {
+			t.Fatalf("%s: 载入的cookie与保存的不同\n期望\n%s\n实际\n%s", name, want, got)
+		}
+	}
+}
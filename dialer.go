@@ -0,0 +1,157 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+//建立连接时使用的IP协议版本
+type IPVersion int
+
+const (
+	IPAny     IPVersion = iota //由系统决定,同时有IPv4及IPv6地址时两者竞速(Happy Eyeballs)
+	IPv4Only                   //只使用IPv4
+	IPv6Only                   //只使用IPv6
+	IPv4First                  //先尝试全部IPv4地址,都失败后再尝试IPv6
+	IPv6First                  //先尝试全部IPv6地址,都失败后再尝试IPv4
+)
+
+//默认的SetLinger参数,关闭连接时最多等待3秒发送未发送完的数据
+const defaultLinger = 3
+
+/*
+建立TCP连接,标准库的传输层及有序传输层共用,连接代理服务器时同样使用
+设置了多个本地IP时轮流使用,本地IP决定了能连接的目标地址类型,IPv4的本地IP只能连接IPv4地址
+按IPv4,IPv6分别连接时,只使用同一协议版本的本地IP,没有该版本的本地IP时跳过该版本
+IPAny时如果同时设置了IPv4及IPv6的本地IP,按IPv4First处理,以免本地IP与目标地址的协议版本不一致
+*/
+type connDialer struct {
+	timeout   time.Duration
+	keepAlive time.Duration
+	linger    int
+	ipVersion IPVersion
+	local     localIPs //全部本地IP,只有一种协议版本时使用
+	local4    localIPs //IPv4的本地IP
+	local6    localIPs //IPv6的本地IP
+}
+
+//轮流使用的本地IP
+type localIPs struct {
+	ips  []net.IP
+	next *uint32 //下一次使用的下标
+}
+
+func newConnDialer(tc transportConfig) *connDialer {
+	d := &connDialer{
+		timeout:   tc.dialTimeout,
+		keepAlive: tc.keepAlive,
+		linger:    tc.linger,
+		ipVersion: tc.ipVersion,
+		local:     localIPs{ips: tc.localAddrs, next: new(uint32)},
+		local4:    localIPs{next: new(uint32)},
+		local6:    localIPs{next: new(uint32)},
+	}
+	for _, ip := range tc.localAddrs {
+		if ip.To4() != nil {
+			d.local4.ips = append(d.local4.ips, ip)
+		} else {
+			d.local6.ips = append(d.local6.ips, ip)
+		}
+	}
+	return d
+}
+
+//连接addr(host:port),network为"tcp"
+func (d *connDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	version := d.ipVersion
+	if version == IPAny && len(d.local4.ips) > 0 && len(d.local6.ips) > 0 {
+		version = IPv4First
+	}
+	switch version {
+	case IPv4Only:
+		conn, err = d.dial(ctx, "tcp4", addr)
+	case IPv6Only:
+		conn, err = d.dial(ctx, "tcp6", addr)
+	case IPv4First:
+		conn, err = d.dialPreferred(ctx, "tcp4", "tcp6", addr)
+	case IPv6First:
+		conn, err = d.dialPreferred(ctx, "tcp6", "tcp4", addr)
+	default:
+		conn, err = d.dial(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok && d.linger >= 0 {
+		tc.SetLinger(d.linger)
+	}
+	return conn, nil
+}
+
+//按network("tcp","tcp4","tcp6")连接,使用同一协议版本的本地IP
+func (d *connDialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	local := d.local
+	switch network {
+	case "tcp4":
+		local = d.local4
+	case "tcp6":
+		local = d.local6
+	}
+	dialer := &net.Dialer{Timeout: d.timeout, KeepAlive: d.keepAlive}
+	if len(d.local.ips) > 0 {
+		if len(local.ips) == 0 {
+			return nil, &net.AddrError{Err: "no local address for " + network, Addr: addr}
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: local.pick()}
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+//轮流取一个本地IP
+func (l localIPs) pick() net.IP {
+	i := atomic.AddUint32(l.next, 1) - 1
+	return l.ips[i%uint32(len(l.ips))]
+}
+
+//先用first连接,失败后再用second连接,每次都有完整的连接超时时间
+//两次都失败时,优先返回不是"没有该类型地址"的错误
+func (d *connDialer) dialPreferred(ctx context.Context, first, second, addr string) (net.Conn, error) {
+	conn, err := d.dial(ctx, first, addr)
+	if err == nil || ctx.Err() != nil {
+		return conn, err
+	}
+	conn, err2 := d.dial(ctx, second, addr)
+	if err2 == nil {
+		return conn, nil
+	}
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) {
+		return nil, err2
+	}
+	return nil, err
+}
+
+//解析本地IP,用于WithLocalAddr
+func parseLocalAddrs(addrs []string) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("本地IP地址有误: %q", addr)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
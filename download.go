@@ -0,0 +1,508 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//下载内容的校验值与DownloadOptions.Checksum不一致时返回的错误,可通过errors.Is判断
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+//下载过程中超过DownloadOptions.IdleTimeout没有收到任何数据时返回的错误,可通过errors.Is判断
+var ErrDownloadStalled = errors.New("download stalled")
+
+//DownloadOptions.IdleTimeout的默认值
+const defaultDownloadIdleTimeout = 60 * time.Second
+
+/*
+下载的可选参数,为nil时全部使用默认值
+Checksum为期望的校验值,形如"sha256:9f86d08...",支持md5,sha1,sha256,sha512,不一致时返回ErrChecksumMismatch
+Progress在每次写入后调用,downloaded包括断点续传之前已下载的部分,total为-1表示服务器没有返回长度
+Timeout为整个下载过程的超时时间,为0表示不限制,此时GatherStruct的超时设置对下载不起作用,可通过ctx控制
+IdleTimeout为连接及下载过程中允许多长时间没有收到任何数据,超过时中止下载并返回ErrDownloadStalled,
+为0时为60秒,小于0表示不限制,适合下载所需时间无法预计的大文件
+*/
+type DownloadOptions struct {
+	Referer  string
+	Cookies  string
+	Headers  map[string]string
+	NoResume bool //Download时不使用断点续传,总是重新下载
+	Checksum string
+	Progress func(downloaded, total int64)
+	Timeout  time.Duration
+	//没有收到任何数据的最长时间
+	IdleTimeout time.Duration
+}
+
+//下载的结果
+type DownloadResult struct {
+	StatusCode int
+	Header     http.Header
+	FinalURL   string        //跳转后最终下载的URL
+	Size       int64         //下载完成后的总大小,包括断点续传之前已下载的部分
+	Written    int64         //本次实际下载的字节数
+	Resumed    bool          //是否从上次中断的位置继续下载
+	Elapsed    time.Duration //本次下载用时
+}
+
+//断点续传时与.part文件一起保存的校验信息,服务器上的文件变化后不能继续下载
+type downloadState struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+//续传时的If-Range,弱ETag不能用于Range请求
+func (s *downloadState) ifRange() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+/*
+下载URL的内容并保存到文件dest,边下载边写入,不会把全部内容读入内存
+下载过程中内容保存在dest+".part",完成后才改名为dest;中断后再次调用时,如果服务器支持Range请求,从中断的位置继续下载
+服务器上的文件发生变化(ETag或Last-Modified不同)时自动重新下载
+
+例:
+ga := gather.NewGather("chrome", false)
+result, err := ga.Download("https://www.example.com/1.zip", "D:/1.zip", &gather.DownloadOptions{
+Checksum: "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+Progress: func(downloaded, total int64) { fmt.Println(downloaded, total) },
+})
+*/
+func (g *GatherStruct) Download(URL, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	return g.DownloadCtx(context.Background(), URL, dest, opts)
+}
+
+//下载URL的内容并保存到文件dest,ctx取消或超时后立即中止下载,已下载的部分保留以便续传,其余参数含义与Download相同
+func (g *GatherStruct) DownloadCtx(ctx context.Context, URL, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	h, expected, err := parseChecksum(opts.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	partFile, stateFile := dest+".part", dest+".part.json"
+	//读取上次中断时的状态
+	var offset int64
+	var state downloadState
+	if !opts.NoResume {
+		if info, err := os.Stat(partFile); err == nil && info.Size() > 0 {
+			if data, err := ioutil.ReadFile(stateFile); err == nil && json.Unmarshal(data, &state) == nil &&
+				state.URL == URL && state.ifRange() != "" {
+				offset = info.Size()
+			}
+		}
+	}
+	start := time.Now()
+	resp, err := g.downloadResponse(ctx, URL, offset, state.ifRange(), opts)
+	if err != nil {
+		return nil, err
+	}
+	//重新下载时resp会被替换,返回时关闭的是最后一个
+	defer func() { resp.Body.Close() }()
+	//无法续传时丢弃已下载的部分,不带Range重新下载
+	restart := func() error {
+		resp.Body.Close()
+		os.Remove(partFile)
+		os.Remove(stateFile)
+		offset = 0
+		next, err := g.downloadResponse(ctx, URL, 0, "", opts)
+		if err != nil {
+			return err
+		}
+		resp = next
+		return nil
+	}
+	//已下载的部分就是完整的文件
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		if total := contentRangeTotal(resp.Header.Get("Content-Range")); total == offset {
+			result := newDownloadResult(resp, start)
+			result.Size, result.Resumed = offset, true
+			return result, finishDownload(partFile, stateFile, dest, h, expected)
+		}
+		if err := restart(); err != nil {
+			return nil, err
+		}
+	}
+	//返回的内容不是从续传位置开始的,不能接在已下载的部分后面
+	if resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp.Header.Get("Content-Range")) != offset {
+		if err := restart(); err != nil {
+			return nil, err
+		}
+	}
+	if !isSuccessStatus(resp.StatusCode, nil) {
+		return nil, downloadStatusError(resp)
+	}
+	if resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) != offset {
+		return nil, fmt.Errorf("下载失败,服务器返回的Content-Range与请求不符: %q", resp.Header.Get("Content-Range"))
+	}
+	//服务器不支持Range,或文件已变化时返回200,需要从头下载
+	if resp.StatusCode != http.StatusPartialContent {
+		offset = 0
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(partFile, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	state = downloadState{URL: URL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if data, err := json.Marshal(state); err == nil {
+		ioutil.WriteFile(stateFile, data, 0644)
+	}
+	result := newDownloadResult(resp, start)
+	result.Resumed = offset > 0
+	result.Written, err = copyDownload(f, resp, offset, opts.Progress)
+	result.Size = offset + result.Written
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+	if err := f.Close(); err != nil {
+		return result, err
+	}
+	if total := downloadTotal(resp, offset); total >= 0 && result.Size != total {
+		return result, fmt.Errorf("下载不完整,已下载%d字节,共%d字节: %w", result.Size, total, io.ErrUnexpectedEOF)
+	}
+	return result, finishDownload(partFile, stateFile, dest, h, expected)
+}
+
+/*
+下载URL的内容并写入w,边下载边写入,不会把全部内容读入内存,不支持断点续传
+opts中的Checksum,Progress,Timeout等含义与Download相同
+
+例:
+ga := gather.NewGather("chrome", false)
+var buf bytes.Buffer
+result, err := ga.DownloadTo("https://www.example.com/1.jpg", &buf, nil)
+*/
+func (g *GatherStruct) DownloadTo(URL string, w io.Writer, opts *DownloadOptions) (*DownloadResult, error) {
+	return g.DownloadToCtx(context.Background(), URL, w, opts)
+}
+
+//下载URL的内容并写入w,ctx取消或超时后立即中止下载,其余参数含义与DownloadTo相同
+func (g *GatherStruct) DownloadToCtx(ctx context.Context, URL string, w io.Writer, opts *DownloadOptions) (*DownloadResult, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	h, expected, err := parseChecksum(opts.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := g.downloadResponse(ctx, URL, 0, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !isSuccessStatus(resp.StatusCode, nil) {
+		return nil, downloadStatusError(resp)
+	}
+	if h != nil {
+		w = io.MultiWriter(w, h)
+	}
+	result := newDownloadResult(resp, start)
+	result.Written, err = copyDownload(w, resp, 0, opts.Progress)
+	result.Size = result.Written
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+	if total := downloadTotal(resp, 0); total >= 0 && result.Size != total {
+		return result, fmt.Errorf("下载不完整,已下载%d字节,共%d字节: %w", result.Size, total, io.ErrUnexpectedEOF)
+	}
+	if h != nil && hex.EncodeToString(h.Sum(nil)) != expected {
+		return result, ErrChecksumMismatch
+	}
+	return result, nil
+}
+
+//发送下载请求,offset大于0时只请求offset之后的部分
+//超过IdleTimeout没有收到数据时中止,关闭resp.Body时停止计时
+func (g *GatherStruct) downloadResponse(ctx context.Context, URL string, offset int64, ifRange string, opts *DownloadOptions) (*http.Response, error) {
+	idle := opts.IdleTimeout
+	if idle == 0 {
+		idle = defaultDownloadIdleTimeout
+	}
+	var watchdog *idleWatchdog
+	if idle > 0 {
+		ctx, watchdog = newIdleWatchdog(ctx, idle)
+	}
+	req, err := g.newHttpRequest(ctx, "GET", URL, nil, "", &RequestOptions{Headers: opts.Headers, Referer: opts.Referer, Cookies: opts.Cookies})
+	if err != nil {
+		watchdog.stop()
+		return nil, err
+	}
+	//Range按未压缩的原始内容计算,因此不接受压缩
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		req.Header.Set("If-Range", ifRange)
+	}
+	req, _ = withRedirectState(req, nil)
+	//下载大文件所需的时间无法预计,不使用GatherStruct的超时设置
+	client := *g.Client
+	client.Timeout = opts.Timeout
+	resp, err := g.sendWith(&client, req)
+	if err != nil {
+		return nil, watchdog.fail(err)
+	}
+	if watchdog != nil {
+		resp.Body = &idleWatchdogBody{ReadCloser: resp.Body, w: watchdog}
+	}
+	return resp, nil
+}
+
+//超过timeout没有收到数据时取消ctx,watchdog为nil时各方法什么都不做
+type idleWatchdog struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+	idle   time.Duration
+}
+
+func newIdleWatchdog(ctx context.Context, idle time.Duration) (context.Context, *idleWatchdog) {
+	w := &idleWatchdog{idle: idle}
+	w.ctx, w.cancel = context.WithCancelCause(ctx)
+	w.timer = time.AfterFunc(idle, func() {
+		w.cancel(fmt.Errorf("超过%v没有收到任何数据: %w", idle, ErrDownloadStalled))
+	})
+	return w.ctx, w
+}
+
+//收到数据后重新计时
+func (w *idleWatchdog) kick() {
+	if w != nil {
+		w.timer.Reset(w.idle)
+	}
+}
+
+func (w *idleWatchdog) stop() {
+	if w != nil {
+		w.timer.Stop()
+		w.cancel(nil)
+	}
+}
+
+//因没有收到数据而中止时,把err换成ErrDownloadStalled,并停止计时
+func (w *idleWatchdog) fail(err error) error {
+	if w == nil {
+		return err
+	}
+	cause := context.Cause(w.ctx)
+	w.stop()
+	if errors.Is(cause, ErrDownloadStalled) {
+		return cause
+	}
+	return err
+}
+
+//读取响应内容时,每收到数据就重新计时
+type idleWatchdogBody struct {
+	io.ReadCloser
+	w *idleWatchdog
+}
+
+func (b *idleWatchdogBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.kick()
+	}
+	if err != nil && err != io.EOF {
+		err = b.w.fail(err)
+	}
+	return n, err
+}
+
+func (b *idleWatchdogBody) Close() error {
+	b.w.stop()
+	return b.ReadCloser.Close()
+}
+
+//把响应内容写入w,每次写入后报告进度
+func copyDownload(w io.Writer, resp *http.Response, offset int64, progress func(downloaded, total int64)) (int64, error) {
+	if progress == nil {
+		return io.Copy(w, resp.Body)
+	}
+	total := downloadTotal(resp, offset)
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			nw, werr := w.Write(buf[:n])
+			written += int64(nw)
+			progress(offset+written, total)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+//下载完成后文件的总大小,为-1表示未知
+func downloadTotal(resp *http.Response, offset int64) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		if total := contentRangeTotal(resp.Header.Get("Content-Range")); total >= 0 {
+			return total
+		}
+	}
+	if resp.ContentLength >= 0 {
+		return offset + resp.ContentLength
+	}
+	return -1
+}
+
+//Content-Range形如"bytes 100-199/1000"或"bytes */1000",返回起始位置,无法解析时为-1
+func contentRangeStart(v string) int64 {
+	v = strings.TrimPrefix(v, "bytes ")
+	if i := strings.IndexByte(v, '-'); i > 0 {
+		if n, err := strconv.ParseInt(v[:i], 10, 64); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+//返回Content-Range中的总长度,无法解析或为*时为-1
+func contentRangeTotal(v string) int64 {
+	if i := strings.LastIndexByte(v, '/'); i >= 0 {
+		if n, err := strconv.ParseInt(v[i+1:], 10, 64); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+func newDownloadResult(resp *http.Response, start time.Time) *DownloadResult {
+	return &DownloadResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		FinalURL:   resp.Request.URL.String(),
+		Elapsed:    time.Since(start),
+	}
+}
+
+//下载失败时,读取少量响应内容用于生成StatusError
+func downloadStatusError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return newStatusError(&Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+		FinalURL:   resp.Request.URL.String(),
+	})
+}
+
+//校验下载完成的.part文件,通过后改名为dest;校验失败时删除,以免下次从错误的内容继续下载
+func finishDownload(partFile, stateFile, dest string, h hash.Hash, expected string) error {
+	if h != nil {
+		f, err := os.Open(partFile)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != expected {
+			os.Remove(partFile)
+			os.Remove(stateFile)
+			return ErrChecksumMismatch
+		}
+	}
+	if err := os.Rename(partFile, dest); err != nil {
+		return err
+	}
+	os.Remove(stateFile)
+	return nil
+}
+
+//解析形如"sha256:9f86d08..."的校验值,为空时返回nil
+func parseChecksum(checksum string) (hash.Hash, string, error) {
+	if checksum == "" {
+		return nil, "", nil
+	}
+	i := strings.IndexByte(checksum, ':')
+	if i < 0 {
+		return nil, "", fmt.Errorf("校验值格式错误,应形如sha256:9f86d08...: %s", checksum)
+	}
+	expected := strings.ToLower(checksum[i+1:])
+	switch strings.ToLower(checksum[:i]) {
+	case "md5":
+		return md5.New(), expected, nil
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	case "sha512":
+		return sha512.New(), expected, nil
+	}
+	return nil, "", fmt.Errorf("不支持的校验算法: %s", checksum[:i])
+}
+
+//从缓存池中 随便获取一个,下载URL的内容并保存到文件dest,参数含义与GatherStruct.Download相同
+func (p *Pool) Download(URL, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	return p.DownloadCtx(context.Background(), URL, dest, opts)
+}
+
+//从缓存池中 随便获取一个,下载URL的内容并保存到文件dest,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) DownloadCtx(ctx context.Context, URL, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	pool_index, err := p.getPoolIndexCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].DownloadCtx(ctx, URL, dest, opts)
+}
+
+//从缓存池中 随便获取一个,下载URL的内容并写入w,参数含义与GatherStruct.DownloadTo相同
+func (p *Pool) DownloadTo(URL string, w io.Writer, opts *DownloadOptions) (*DownloadResult, error) {
+	return p.DownloadToCtx(context.Background(), URL, w, opts)
+}
+
+//从缓存池中 随便获取一个,下载URL的内容并写入w,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) DownloadToCtx(ctx context.Context, URL string, w io.Writer, opts *DownloadOptions) (*DownloadResult, error) {
+	pool_index, err := p.getPoolIndexCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].DownloadToCtx(ctx, URL, w, opts)
+}
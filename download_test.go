@@ -0,0 +1,128 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//按rangeStart决定206响应从哪里开始,rangeStart为nil时按请求的Range返回
+func newRangeServer(content string, rangeStart func(requested int64) int64) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		v := r.Header.Get("Range")
+		if v == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content))
+			return
+		}
+		requested, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(v, "bytes="), "-"), 10, 64)
+		start := requested
+		if rangeStart != nil {
+			start = rangeStart(requested)
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-int(start)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[start:]))
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+//模拟上次中断时留下的.part及.part.json
+func writePartial(t *testing.T, dest, URL, part string) {
+	if err := ioutil.WriteFile(dest+".part", []byte(part), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := fmt.Sprintf(`{"url":%q,"etag":"\"v1\""}`, URL)
+	if err := ioutil.WriteFile(dest+".part.json", []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv, ranges := newRangeServer(content, nil)
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	writePartial(t, dest, srv.URL, content[:300])
+	result, err := New().Download(srv.URL, dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Resumed || result.Written != 700 || result.Size != 1000 {
+		t.Fatalf("续传结果有误: %+v", result)
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != content {
+		t.Fatal("续传后的文件内容有误")
+	}
+	if got := strings.Join(ranges(), ","); got != "bytes=300-" {
+		t.Fatalf("请求的Range有误: %s", got)
+	}
+}
+
+//206的起始位置与续传位置不同时,丢弃已下载的部分,不带Range重新下载
+func TestDownloadResumeRangeMismatch(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv, ranges := newRangeServer(content, func(requested int64) int64 { return requested + 100 })
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	writePartial(t, dest, srv.URL, content[:300])
+	result, err := New().Download(srv.URL, dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Resumed || result.Size != 1000 {
+		t.Fatalf("重新下载的结果有误: %+v", result)
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != content {
+		t.Fatal("重新下载后的文件内容有误")
+	}
+	if got := strings.Join(ranges(), ","); got != "bytes=300-," {
+		t.Fatalf("期望先续传再不带Range重新下载, 实际的Range: %q", got)
+	}
+	for _, f := range []string{dest + ".part", dest + ".part.json"} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Fatalf("%s应被删除", f)
+		}
+	}
+}
+
+//没有请求Range时服务器却返回了中间的内容,不能当作文件的开头保存
+func TestDownloadUnrequestedPartialContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 5-9/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("56789"))
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	if _, err := New().Download(srv.URL, dest, nil); err == nil {
+		t.Fatal("期望返回错误")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatal("不应生成目标文件")
+	}
+}
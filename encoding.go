@@ -0,0 +1,189 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+//默认Request Headers中的Accept-Encoding,只包含能够自动解压的压缩方式
+const defaultAcceptEncoding = "gzip, deflate, br, zstd"
+
+//解压后内容的默认最大长度
+const defaultMaxDecompressedSize = 256 << 20
+
+//解压后的内容超过GatherStruct.MaxDecompressedSize时返回的错误,可通过errors.Is判断
+var ErrDecompressedTooLarge = errors.New("decompressed body too large")
+
+/*
+按响应头中的Content-Encoding解压响应内容,支持gzip,deflate,br,zstd及它们的组合
+解压后从resp.Header中删除Content-Encoding及Content-Length,与标准库自动解压gzip时的处理一致
+遇到不支持的压缩方式,或没有响应内容(HEAD,204,304及空内容)时不解压,原样返回
+*/
+func (g *GatherStruct) decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	encodings := parseContentEncoding(resp.Header.Get("Content-Encoding"))
+	if len(encodings) == 0 || !mayHaveBody(resp) {
+		return resp.Body, nil
+	}
+	for _, encoding := range encodings {
+		if !isSupportedEncoding(encoding) {
+			return resp.Body, nil
+		}
+	}
+	//服务器对空内容也可能带上Content-Encoding,此时gzip等解压器会返回EOF
+	br := bufio.NewReader(resp.Body)
+	if _, err := br.Peek(1); err == io.EOF {
+		return resp.Body, nil
+	}
+	var r io.Reader = br
+	var closers []io.Closer
+	//多次压缩时按相反的顺序解压
+	for i := len(encodings) - 1; i >= 0; i-- {
+		dr, closer, err := newDecoder(r, encodings[i])
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return nil, fmt.Errorf("解压%s失败: %w", encodings[i], err)
+		}
+		r = dr
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	maxSize := g.MaxDecompressedSize
+	if maxSize == 0 {
+		maxSize = defaultMaxDecompressedSize
+	}
+	if maxSize > 0 {
+		r = &sizeLimitReader{r: r, remaining: maxSize, err: ErrDecompressedTooLarge}
+	}
+	return &decodedBody{Reader: r, closers: append(closers, resp.Body)}, nil
+}
+
+//响应是否可能有内容,HEAD请求及204,304响应总是没有内容
+func mayHaveBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == "HEAD" {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+//Content-Encoding中的压缩方式,不含identity
+func parseContentEncoding(v string) []string {
+	var encodings []string
+	for _, encoding := range strings.Split(v, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return encodings
+}
+
+func isSupportedEncoding(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+//返回解压用的Reader,closer不为nil时读取完后需要关闭
+func newDecoder(r io.Reader, encoding string) (io.Reader, io.Closer, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr, nil
+	case "deflate":
+		//按RFC应为zlib格式,但不少服务器直接发送不带zlib头的deflate数据
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, zr, nil
+		}
+		fr := flate.NewReader(br)
+		return fr, fr, nil
+	case "br":
+		return brotli.NewReader(r), nil, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.IOReadCloser(), nil
+	}
+	return nil, nil, fmt.Errorf("不支持的压缩方式: %s", encoding)
+}
+
+//RFC 1950 zlib头:压缩方法为8,且前两个字节组成的数能被31整除
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+//解压后的响应内容,关闭时依次关闭各层解压器及原始的响应内容
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//读取超过remaining字节时返回err,防止解压炸弹等超大内容耗尽内存
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+	//多读一个字节,以区分内容恰好等于上限与超过上限
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err
+	}
+	return n, err
+}
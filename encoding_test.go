@@ -0,0 +1,197 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+//解压后的内容
+var testBody = []byte(strings.Repeat("<p>采集测试 gather encoding test</p>\n", 200))
+
+//按encoding压缩data
+func encodeBody(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("不支持的压缩方式%s", encoding)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newEncodedResponse(method string, status int, contentEncoding string, body []byte) *http.Response {
+	req, _ := http.NewRequest(method, "http://www.example.com/", nil)
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Encoding": {contentEncoding}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		contentEncoding string
+		encode          []string //按顺序压缩
+	}{
+		{"gzip", []string{"gzip"}},
+		{"x-gzip", []string{"gzip"}},
+		{"GZIP", []string{"gzip"}},
+		{"deflate", []string{"deflate"}},
+		{"deflate", []string{"raw-deflate"}},
+		{"br", []string{"br"}},
+		{"zstd", []string{"zstd"}},
+		{"identity, gzip", []string{"gzip"}},
+		{"gzip, br", []string{"gzip", "br"}},
+		{"br,zstd,  deflate", []string{"br", "zstd", "raw-deflate"}},
+	}
+	for _, test := range tests {
+		data := testBody
+		for _, encoding := range test.encode {
+			data = encodeBody(t, encoding, data)
+		}
+		resp := newEncodedResponse("GET", 200, test.contentEncoding, data)
+		body, err := new(GatherStruct).decodeBody(resp)
+		if err != nil {
+			t.Fatal(test.contentEncoding, err)
+		}
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatal(test.contentEncoding, err)
+		}
+		if !bytes.Equal(got, testBody) {
+			t.Fatalf("%s %v: 解压后的内容有误", test.contentEncoding, test.encode)
+		}
+		if resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != -1 {
+			t.Fatalf("%s: 解压后应删除Content-Encoding及Content-Length", test.contentEncoding)
+		}
+	}
+}
+
+//不支持的压缩方式及没有内容的响应原样返回
+func TestDecodeBodyPassThrough(t *testing.T) {
+	gz := encodeBody(t, "gzip", testBody)
+	tests := []struct {
+		name            string
+		method          string
+		status          int
+		contentEncoding string
+		body            []byte
+	}{
+		{"不支持的压缩方式", "GET", 200, "compress", []byte("raw")},
+		{"组合中有不支持的压缩方式", "GET", 200, "gzip, compress", gz},
+		{"HEAD", "HEAD", 200, "gzip", nil},
+		{"204", "GET", 204, "gzip", nil},
+		{"304", "GET", 304, "br", nil},
+		{"空内容", "GET", 200, "zstd", nil},
+	}
+	for _, test := range tests {
+		resp := newEncodedResponse(test.method, test.status, test.contentEncoding, test.body)
+		//长度未知的空内容同样不解压
+		if test.name == "空内容" {
+			resp.ContentLength = -1
+		}
+		body, err := new(GatherStruct).decodeBody(resp)
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		got, err := ioutil.ReadAll(body)
+		if err != nil || !bytes.Equal(got, test.body) {
+			t.Fatalf("%s: 期望原样返回, 实际%q %v", test.name, got, err)
+		}
+		if resp.Header.Get("Content-Encoding") != test.contentEncoding {
+			t.Fatalf("%s: 不应删除Content-Encoding", test.name)
+		}
+	}
+}
+
+func TestDecodeBodySizeLimit(t *testing.T) {
+	size := int64(len(testBody))
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		data := encodeBody(t, encoding, testBody)
+		for _, limit := range []int64{size - 1, size, -1} {
+			g := &GatherStruct{MaxDecompressedSize: limit}
+			body, err := g.decodeBody(newEncodedResponse("GET", 200, encoding, data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(body)
+			body.Close()
+			if limit == size-1 {
+				if !errors.Is(err, ErrDecompressedTooLarge) {
+					t.Fatalf("%s: 超过上限%d时期望返回ErrDecompressedTooLarge, 实际%v", encoding, limit, err)
+				}
+				if int64(len(got)) > limit {
+					t.Fatalf("%s: 读出了%d字节, 超过上限%d", encoding, len(got), limit)
+				}
+				continue
+			}
+			if err != nil || !bytes.Equal(got, testBody) {
+				t.Fatalf("%s: 上限%d时期望正常解压, 实际%v", encoding, limit, err)
+			}
+		}
+	}
+	//损坏的压缩内容返回错误
+	if _, err := new(GatherStruct).decodeBody(newEncodedResponse("GET", 200, "gzip", []byte("not gzip"))); err == nil {
+		t.Fatal("损坏的gzip内容应返回错误")
+	}
+}
+
+//通过GatherStruct请求时自动解压
+func TestDecodeBodyRequest(t *testing.T) {
+	data := encodeBody(t, "gzip", encodeBody(t, "br", testBody))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "br, gzip")
+		w.Write(data)
+	}))
+	defer srv.Close()
+	for _, ordered := range []bool{false, true} {
+		ga := New(WithOrderedHeaders(ordered))
+		html, _, err := ga.Get(srv.URL, "")
+		if err != nil || html != string(testBody) {
+			t.Fatalf("ordered=%v: 解压后的内容有误: %v", ordered, err)
+		}
+		ga.Close()
+	}
+}
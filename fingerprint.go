@@ -0,0 +1,132 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+/*
+浏览器的TLS及HTTP/2指纹
+Go标准库的TLS ClientHello(JA3/JA4)及HTTP/2 SETTINGS与任何浏览器都不同,即使User-Agent一致也能被识别出来
+开启WithBrowserFingerprint后,有序传输层按Profile.Fingerprint发送与对应浏览器一致的ClientHello及SETTINGS
+*/
+type fingerprint struct {
+	hello             utls.ClientHelloID
+	h2Settings        []http2.Setting //建立HTTP/2连接后发送的SETTINGS,顺序即发送顺序
+	h2WindowIncrement uint32          //紧接着SETTINGS发送的连接级别WINDOW_UPDATE
+}
+
+//HTTP/2的SETTINGS_NO_RFC7540_PRIORITIES,x/net/http2中没有定义
+const settingNoRFC7540Priorities http2.SettingID = 0x9
+
+//内置的指纹,Profile.Fingerprint只能使用这些名称
+var fingerprints = map[string]*fingerprint{
+	"chrome": {
+		hello:             utls.HelloChrome_Auto,
+		h2Settings:        defaultH2Settings,
+		h2WindowIncrement: defaultH2ConnWindowIncrement,
+	},
+	"firefox": {
+		hello: utls.HelloFirefox_Auto,
+		h2Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingInitialWindowSize, Val: 131072},
+			{ID: http2.SettingMaxFrameSize, Val: 16384},
+		},
+		h2WindowIncrement: 12517377,
+	},
+	"safari": {
+		hello: utls.HelloSafari_Auto,
+		h2Settings: []http2.Setting{
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+			{ID: http2.SettingInitialWindowSize, Val: 2097152},
+			{ID: settingNoRFC7540Priorities, Val: 1},
+		},
+		h2WindowIncrement: 10420225,
+	},
+}
+
+//全部内置指纹的名称,按字母排序
+func FingerprintNames() []string {
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//按名称查找指纹,名称为空时返回nil,即使用Go标准库的TLS
+func fingerprintFor(name string) (*fingerprint, error) {
+	if name == "" {
+		return nil, nil
+	}
+	fp, exist := fingerprints[strings.ToLower(name)]
+	if !exist {
+		return nil, fmt.Errorf("不支持的指纹%q,可用的指纹: %s", name, strings.Join(FingerprintNames(), ","))
+	}
+	return fp, nil
+}
+
+//HTTP/2连接的SETTINGS及WINDOW_UPDATE,未设置指纹时与Chrome一致
+func (fp *fingerprint) h2() ([]http2.Setting, uint32) {
+	if fp == nil {
+		return defaultH2Settings, defaultH2ConnWindowIncrement
+	}
+	return fp.h2Settings, fp.h2WindowIncrement
+}
+
+/*
+用utls按指纹完成TLS握手,返回的连接与标准库的握手结果用法相同
+ALPN由指纹决定,与浏览器一样总是同时提供h2及http/1.1,tlsConfig.NextProtos不起作用
+*/
+func (fp *fingerprint) handshake(ctx context.Context, conn net.Conn, cfg *tls.Config) (net.Conn, *tls.ConnectionState, error) {
+	ucfg := &utls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		RootCAs:            cfg.RootCAs,
+		MinVersion:         cfg.MinVersion,
+		MaxVersion:         cfg.MaxVersion,
+		KeyLogWriter:       cfg.KeyLogWriter,
+	}
+	for _, c := range cfg.Certificates {
+		ucfg.Certificates = append(ucfg.Certificates, utls.Certificate{
+			Certificate: c.Certificate,
+			PrivateKey:  c.PrivateKey,
+			OCSPStaple:  c.OCSPStaple,
+			Leaf:        c.Leaf,
+		})
+	}
+	uconn := utls.UClient(conn, ucfg, fp.hello)
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	s := uconn.ConnectionState()
+	return uconn, &tls.ConnectionState{
+		Version:                     s.Version,
+		HandshakeComplete:           s.HandshakeComplete,
+		DidResume:                   s.DidResume,
+		CipherSuite:                 s.CipherSuite,
+		NegotiatedProtocol:          s.NegotiatedProtocol,
+		NegotiatedProtocolIsMutual:  s.NegotiatedProtocolIsMutual,
+		ServerName:                  s.ServerName,
+		PeerCertificates:            s.PeerCertificates,
+		VerifiedChains:              s.VerifiedChains,
+		SignedCertificateTimestamps: s.SignedCertificateTimestamps,
+		OCSPResponse:                s.OCSPResponse,
+	}, nil
+}
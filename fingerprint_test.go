@@ -0,0 +1,215 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+//ClientHello中与指纹有关的部分
+type clientHello struct {
+	ciphers    []uint16 //按发送顺序,不含GREASE
+	extensions []uint16 //按数值排序,不含GREASE及padding,Chrome每次连接都会打乱扩展的顺序
+	alpn       []string
+}
+
+func (h clientHello) String() string {
+	return fmt.Sprintf("ciphers=%x extensions=%x alpn=%v", h.ciphers, h.extensions, h.alpn)
+}
+
+//padding扩展,长度随ClientHello变化,不一定出现
+const extensionPadding = 21
+
+//GREASE值见RFC 8701,浏览器每次随机选取
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func newClientHello(ciphers, extensions []uint16, alpn []string) clientHello {
+	var h clientHello
+	for _, c := range ciphers {
+		if !isGREASE(c) {
+			h.ciphers = append(h.ciphers, c)
+		}
+	}
+	for _, e := range extensions {
+		if !isGREASE(e) && e != extensionPadding {
+			h.extensions = append(h.extensions, e)
+		}
+	}
+	sort.Slice(h.extensions, func(i, j int) bool { return h.extensions[i] < h.extensions[j] })
+	h.alpn = alpn
+	return h
+}
+
+//用utls按同一个ClientHelloID生成一次ClientHello,作为期望的结果
+func expectedClientHello(t *testing.T, id utls.ClientHelloID) clientHello {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	uconn := utls.UClient(c1, &utls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true}, id)
+	if err := uconn.BuildHandshakeState(); err != nil {
+		t.Fatal(err)
+	}
+	hello := uconn.HandshakeState.Hello
+	extensions, err := helloExtensions(hello.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newClientHello(hello.CipherSuites, extensions, hello.AlpnProtocols)
+}
+
+//从ClientHello的原始数据中取出全部扩展的类型
+func helloExtensions(raw []byte) ([]uint16, error) {
+	errMalformed := errors.New("ClientHello格式有误")
+	//消息类型(1),长度(3),版本(2),随机数(32)
+	p := 38
+	skip := func(lenBytes int) bool {
+		if p+lenBytes > len(raw) {
+			return false
+		}
+		n := 0
+		for i := 0; i < lenBytes; i++ {
+			n = n<<8 | int(raw[p+i])
+		}
+		p += lenBytes + n
+		return p <= len(raw)
+	}
+	//session id,cipher suites,compression methods
+	if !skip(1) || !skip(2) || !skip(1) || p+2 > len(raw) {
+		return nil, errMalformed
+	}
+	p += 2
+	var extensions []uint16
+	for p < len(raw) {
+		if p+4 > len(raw) {
+			return nil, errMalformed
+		}
+		extensions = append(extensions, uint16(raw[p])<<8|uint16(raw[p+1]))
+		p += 2
+		if !skip(2) {
+			return nil, errMalformed
+		}
+	}
+	return extensions, nil
+}
+
+//记录每个连接的ClientHello的HTTPS服务器
+func newHelloCaptureServer(t *testing.T) (*httptest.Server, func() []clientHello) {
+	var mu sync.Mutex
+	var hellos []clientHello
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		mu.Lock()
+		hellos = append(hellos, newClientHello(info.CipherSuites, info.Extensions, info.SupportedProtos))
+		mu.Unlock()
+		return nil, nil
+	}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, func() []clientHello {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]clientHello(nil), hellos...)
+	}
+}
+
+func TestFingerprintClientHello(t *testing.T) {
+	srv, hellos := newHelloCaptureServer(t)
+	//没有指纹时使用Go标准库的ClientHello
+	std := New(WithOrderedHeaders(true))
+	if _, _, err := std.Get(srv.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+	std.Close()
+	goHello := hellos()[0]
+	seen := map[string]string{goHello.String(): "go"}
+	for _, name := range FingerprintNames() {
+		ga := New(WithProfile(name), WithBrowserFingerprint(true))
+		html, _, err := ga.Get(srv.URL, "")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		//与浏览器一样同时提供h2及http/1.1,服务器支持时使用HTTP/2
+		if html != "HTTP/2.0" {
+			t.Fatalf("%s: 期望使用HTTP/2, 实际%s", name, html)
+		}
+		ga.Close()
+		all := hellos()
+		got := all[len(all)-1]
+		want := expectedClientHello(t, fingerprints[name].hello)
+		if got.String() != want.String() {
+			t.Fatalf("%s: ClientHello有误\n期望 %v\n实际 %v", name, want, got)
+		}
+		if strings.Join(got.alpn, ",") != "h2,http/1.1" {
+			t.Fatalf("%s: ALPN有误: %v", name, got.alpn)
+		}
+		if other, exist := seen[got.String()]; exist {
+			t.Fatalf("%s的ClientHello与%s相同: %v", name, other, got)
+		}
+		seen[got.String()] = name
+	}
+}
+
+//SETTINGS及连接级别的WINDOW_UPDATE与指纹一致,没有指纹时与Chrome一致
+func TestH2Settings(t *testing.T) {
+	for _, name := range []string{"", "chrome", "firefox", "safari"} {
+		srv := newH2CaptureServer(t, false)
+		var ga *GatherStruct
+		if name == "" {
+			ga = New(WithOrderedHeaders(true))
+		} else {
+			ga = New(WithProfile(name), WithBrowserFingerprint(true))
+		}
+		if _, _, err := ga.Get(srv.URL(), ""); err != nil {
+			t.Fatal(name, err)
+		}
+		ga.Close()
+		fp, _ := fingerprintFor(name)
+		settings, increment := fp.h2()
+		srv.mu.Lock()
+		if len(srv.settings) != len(settings) {
+			t.Fatalf("%s: SETTINGS有误, 期望%v, 实际%v", name, settings, srv.settings)
+		}
+		for i := range settings {
+			if srv.settings[i] != settings[i] {
+				t.Fatalf("%s: SETTINGS有误, 期望%v, 实际%v", name, settings, srv.settings)
+			}
+		}
+		if srv.increment != increment {
+			t.Fatalf("%s: WINDOW_UPDATE有误, 期望%d, 实际%d", name, increment, srv.increment)
+		}
+		srv.mu.Unlock()
+	}
+}
+
+func TestFingerprintFor(t *testing.T) {
+	if fp, err := fingerprintFor(""); fp != nil || err != nil {
+		t.Fatal(fp, err)
+	}
+	if fp, err := fingerprintFor("Firefox"); fp != fingerprints["firefox"] || err != nil {
+		t.Fatal(fp, err)
+	}
+	if _, err := fingerprintFor("opera"); err == nil {
+		t.Fatal("不支持的指纹应返回错误")
+	}
+}
@@ -6,6 +6,10 @@
 //模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
 package gather
 
+import (
+	"context"
+)
+
 /*
 GET方式获取数据,自动继承先前的cookies
 URL:指待抓取的URL
@@ -32,13 +36,49 @@ ga:= NewGather("chrome", false)
 cookies:=`SINAGLOBAL=8868584542946.604.1509350660873;??????????; YF-Page-G0=b9385a03a044baf8db46b84f3ff125a0`
 html, redirectURL, err := ga.GetUtil("https://weibo.com/xxxxxx",cookies, "")
 */
-//GET方式获取数据,手动设置Cookie,Cookie留空则自动继承上次抓取时使用的Cookie
+//GET方式获取数据,手动设置Cookie,cookies只对本次抓取生效,之后的抓取不会再发送
 func (g *GatherStruct) GetUtil(URL, refererURL, cookies string) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
-	req, err := g.newHttpRequest("GET", URL, refererURL, cookies, nil)
+	return htmlResult(g.GetResponse(URL, refererURL, cookies))
+}
+
+/*
+GET方式获取数据,返回包含状态码,响应头等信息的完整Response
+参数含义与GetUtil相同,cookies留空则自动继承先前的cookies
+状态码不被认为是成功时(默认为非2xx),Response与*StatusError会同时返回,可从Response中读取出错页面的内容
+
+例:
+ga := NewGather("chrome", false)
+resp, err := ga.GetResponse("https://www.baidu.com/", "", "")
+fmt.Println(resp.StatusCode, resp.Header.Get("Content-Type"), resp.Elapsed)
+*/
+func (g *GatherStruct) GetResponse(URL, refererURL, cookies string) (*Response, error) {
+	return g.GetResponseCtx(context.Background(), URL, refererURL, cookies)
+}
+
+/*
+GET方式获取数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取
+其余参数含义与Get相同
+
+例:
+ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+defer cancel()
+ga := NewGather("chrome", false)
+html, redirectURL, err := ga.GetCtx(ctx, "https://www.baidu.com/", "")
+*/
+func (g *GatherStruct) GetCtx(ctx context.Context, URL, refererURL string) (html, redirectURL string, err error) {
+	return g.GetUtilCtx(ctx, URL, refererURL, "")
+}
+
+//GET方式获取数据,手动设置Cookie,ctx取消或超时后立即中止抓取,其余参数含义与GetUtil相同
+func (g *GatherStruct) GetUtilCtx(ctx context.Context, URL, refererURL, cookies string) (html, redirectURL string, err error) {
+	return htmlResult(g.GetResponseCtx(ctx, URL, refererURL, cookies))
+}
+
+//GET方式获取数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与GetResponse相同
+func (g *GatherStruct) GetResponseCtx(ctx context.Context, URL, refererURL, cookies string) (*Response, error) {
+	req, err := g.newHttpRequest(ctx, "GET", URL, nil, "", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
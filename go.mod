@@ -0,0 +1,16 @@
+module github.com/yudeguang/gather
+
+go 1.25.0
+
+require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
+	github.com/refraction-networking/utls v1.8.2
+	golang.org/x/net v0.57.0
+	golang.org/x/text v0.40.0
+)
+
+require (
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+)
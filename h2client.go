@@ -0,0 +1,743 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+//HTTP/2连接已收到GOAWAY或已关闭,请求尚未被服务器处理,可以换一个连接重新发送
+var errH2Unprocessed = errors.New("http2: request not processed, retry on a new connection")
+
+//响应内容已被调用方关闭
+var errH2BodyClosed = errors.New("http2: response body closed")
+
+/*
+建立HTTP/2连接后发送的SETTINGS及连接级别的WINDOW_UPDATE,取值与Chrome一致
+每个流的接收窗口为InitialWindowSize,连接的接收窗口为65535+ConnWindowIncrement
+*/
+var defaultH2Settings = []http2.Setting{
+	{ID: http2.SettingHeaderTableSize, Val: 65536},
+	{ID: http2.SettingEnablePush, Val: 0},
+	{ID: http2.SettingInitialWindowSize, Val: 6291456},
+	{ID: http2.SettingMaxHeaderListSize, Val: 262144},
+}
+
+const defaultH2ConnWindowIncrement = 15663105
+
+//HTTP/2规定的初始窗口大小
+const h2InitialWindow = 65535
+
+//不能通过HTTP/2发送的Header,Host改为:authority发送
+var h2SkipHeaders = map[string]bool{
+	"host":              true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+//一个HTTP/2连接,可同时处理多个请求
+type h2Conn struct {
+	t    *orderedTransport
+	key  string
+	conn net.Conn
+	tls  *tls.ConnectionState
+
+	wmu  sync.Mutex //保护fr的写入,bw及henc,HEADERS的编码顺序必须与发送顺序一致,持有wmu时可以获取mu,反之不行
+	bw   *bufio.Writer
+	fr   *http2.Framer
+	henc *hpack.Encoder
+	hbuf bytes.Buffer
+
+	mu            sync.Mutex
+	cond          *sync.Cond //等待发送窗口
+	streams       map[uint32]*h2Stream
+	nextID        uint32
+	maxFrameSize  uint32 //对方允许的最大帧
+	maxStreams    uint32 //对方允许的最大并发流数
+	peerWindow    int32  //对方设置的每个流的初始发送窗口
+	sendWindow    int32  //连接级别的发送窗口
+	streamWindow  int32  //我方设置的每个流的接收窗口
+	connUnacked   int32  //已收到但还没有通过WINDOW_UPDATE归还的连接级别窗口
+	connRecvLimit int32  //连接级别的接收窗口
+	goAway        bool
+	closed        bool
+	err           error
+}
+
+//建立HTTP/2连接:发送连接前言,SETTINGS及WINDOW_UPDATE,然后启动读取循环
+func (t *orderedTransport) newH2Conn(key string, conn net.Conn, state *tls.ConnectionState) (*h2Conn, error) {
+	settings, windowIncrement := t.fp.h2()
+	cc := &h2Conn{
+		t:             t,
+		key:           key,
+		conn:          conn,
+		tls:           state,
+		bw:            bufio.NewWriter(conn),
+		streams:       make(map[uint32]*h2Stream),
+		nextID:        1,
+		maxFrameSize:  16384,
+		maxStreams:    1000,
+		peerWindow:    h2InitialWindow,
+		sendWindow:    h2InitialWindow,
+		streamWindow:  h2InitialWindow,
+		connRecvLimit: h2InitialWindow + int32(windowIncrement),
+	}
+	cc.cond = sync.NewCond(&cc.mu)
+	cc.fr = http2.NewFramer(cc.bw, bufio.NewReader(conn))
+	hdec := hpack.NewDecoder(4096, nil)
+	cc.fr.ReadMetaHeaders = hdec
+	cc.fr.MaxHeaderListSize = 262144
+	cc.henc = hpack.NewEncoder(&cc.hbuf)
+	//按我方发送的SETTINGS设置接收参数,对方会按这些值发送
+	for _, s := range settings {
+		switch s.ID {
+		case http2.SettingInitialWindowSize:
+			cc.streamWindow = int32(s.Val)
+		case http2.SettingHeaderTableSize:
+			hdec.SetAllowedMaxDynamicTableSize(s.Val)
+		case http2.SettingMaxHeaderListSize:
+			cc.fr.MaxHeaderListSize = s.Val
+		}
+	}
+	cc.wmu.Lock()
+	_, err := cc.bw.WriteString(http2.ClientPreface)
+	if err == nil {
+		err = cc.fr.WriteSettings(settings...)
+	}
+	if err == nil && windowIncrement > 0 {
+		err = cc.fr.WriteWindowUpdate(0, windowIncrement)
+	}
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	cc.wmu.Unlock()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	t.h2[key] = append(t.h2[key], cc)
+	t.notifyLocked(key)
+	t.mu.Unlock()
+	go cc.readLoop()
+	return cc, nil
+}
+
+//取出一个还可以发送新请求的HTTP/2连接
+func (t *orderedTransport) getH2(key string) *h2Conn {
+	t.mu.Lock()
+	conns := append([]*h2Conn(nil), t.h2[key]...)
+	t.mu.Unlock()
+	for _, cc := range conns {
+		if cc.canTakeNewRequest() {
+			return cc
+		}
+	}
+	return nil
+}
+
+//从可以发送新请求的连接中去掉cc,调用时须持有t.mu
+func (t *orderedTransport) removeH2Locked(cc *h2Conn) {
+	conns := t.h2[cc.key]
+	for i, c := range conns {
+		if c == cc {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(t.h2, cc.key)
+	} else {
+		t.h2[cc.key] = conns
+	}
+}
+
+func (cc *h2Conn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return !cc.closed && !cc.goAway && cc.nextID < 1<<31-1 && uint32(len(cc.streams)) < cc.maxStreams
+}
+
+//没有进行中的请求时关闭连接
+func (cc *h2Conn) closeIfIdle() {
+	cc.mu.Lock()
+	idle := len(cc.streams) == 0
+	cc.mu.Unlock()
+	if idle {
+		cc.fail(errH2Unprocessed)
+	}
+}
+
+//关闭连接,所有进行中的请求以err结束
+func (cc *h2Conn) fail(err error) {
+	cc.mu.Lock()
+	if cc.closed {
+		cc.mu.Unlock()
+		return
+	}
+	cc.closed = true
+	cc.err = err
+	streams := cc.streams
+	cc.streams = make(map[uint32]*h2Stream)
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	cc.conn.Close()
+	for _, st := range streams {
+		st.finish(err)
+	}
+	cc.t.mu.Lock()
+	cc.t.removeH2Locked(cc)
+	cc.t.mu.Unlock()
+}
+
+//加锁写入一个或多个帧并立即发送
+func (cc *h2Conn) write(fn func() error) error {
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	return cc.bw.Flush()
+}
+
+func (cc *h2Conn) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	//流ID必须按发送HEADERS的顺序递增(RFC 7540 5.1.1),所以分配ID与写出HEADERS都在wmu中完成
+	cc.wmu.Lock()
+	cc.mu.Lock()
+	if cc.closed || cc.goAway || cc.nextID >= 1<<31-1 || uint32(len(cc.streams)) >= cc.maxStreams {
+		cc.mu.Unlock()
+		cc.wmu.Unlock()
+		return nil, errH2Unprocessed
+	}
+	st := &h2Stream{
+		cc:         cc,
+		id:         cc.nextID,
+		req:        req,
+		sendWindow: cc.peerWindow,
+		resc:       make(chan h2Result, 1),
+		done:       make(chan struct{}),
+	}
+	st.cond = sync.NewCond(&st.mu)
+	cc.nextID += 2
+	cc.streams[st.id] = st
+	cc.mu.Unlock()
+
+	hasBody := outgoingLength(req) != 0
+	err := cc.writeHeaders(st.id, req, !hasBody)
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	cc.wmu.Unlock()
+	if err != nil {
+		//连接已不可用,请求还没有发出,可以在新连接上重新发送
+		cc.fail(err)
+		return nil, errH2Unprocessed
+	}
+	if hasBody {
+		go st.writeBody(req.Body)
+	} else if req.Body != nil {
+		req.Body.Close()
+	}
+	//ctx取消时重置这个流
+	go func() {
+		select {
+		case <-ctx.Done():
+			st.cancel(ctx.Err())
+		case <-st.done:
+		}
+	}()
+	select {
+	case res := <-st.resc:
+		if res.err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, res.err
+		}
+		return res.resp, nil
+	case <-ctx.Done():
+		st.cancel(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+//编码并写出HEADERS及CONTINUATION,伪头部及普通Header均按headerOrder排列,调用时须已持有cc.wmu
+//Method,Host及Request Headers已在RoundTrip中由validateRequest检查过
+func (cc *h2Conn) writeHeaders(id uint32, req *http.Request, endStream bool) error {
+	order := headerOrderFromContext(req.Context())
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	pseudo := map[string]string{
+		":method":    req.Method,
+		":authority": host,
+		":scheme":    req.URL.Scheme,
+		":path":      req.URL.RequestURI(),
+	}
+	cc.hbuf.Reset()
+	for _, name := range order.pseudoOrder() {
+		if v, exist := pseudo[name]; exist {
+			cc.henc.WriteField(hpack.HeaderField{Name: name, Value: v})
+		}
+	}
+	var extra []headerField
+	if f, ok := contentLengthField(req); ok {
+		extra = append(extra, f)
+	}
+	for _, f := range order.fields(req.Header, extra, true) {
+		if h2SkipHeaders[f.name] || (f.name == "te" && f.value != "trailers") {
+			continue
+		}
+		cc.henc.WriteField(hpack.HeaderField{Name: f.name, Value: f.value})
+	}
+	cc.mu.Lock()
+	maxFrameSize := int(cc.maxFrameSize)
+	cc.mu.Unlock()
+	block := cc.hbuf.Bytes()
+	first := true
+	for first || len(block) > 0 {
+		chunk := block
+		if len(chunk) > maxFrameSize {
+			chunk = chunk[:maxFrameSize]
+		}
+		block = block[len(chunk):]
+		var err error
+		if first {
+			err = cc.fr.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      id,
+				BlockFragment: chunk,
+				EndStream:     endStream,
+				EndHeaders:    len(block) == 0,
+			})
+			first = false
+		} else {
+			err = cc.fr.WriteContinuation(id, len(block) == 0, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//等待发送窗口,返回本次最多可以发送的字节数
+func (cc *h2Conn) awaitSendWindow(st *h2Stream, n int) (int, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if cc.closed {
+			return 0, cc.err
+		}
+		if err := st.sendErr(); err != nil {
+			return 0, err
+		}
+		if st.sendWindow > 0 && cc.sendWindow > 0 {
+			break
+		}
+		cc.cond.Wait()
+	}
+	if int32(n) > st.sendWindow {
+		n = int(st.sendWindow)
+	}
+	if int32(n) > cc.sendWindow {
+		n = int(cc.sendWindow)
+	}
+	if uint32(n) > cc.maxFrameSize {
+		n = int(cc.maxFrameSize)
+	}
+	st.sendWindow -= int32(n)
+	cc.sendWindow -= int32(n)
+	return n, nil
+}
+
+func (cc *h2Conn) stream(id uint32) *h2Stream {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.streams[id]
+}
+
+func (cc *h2Conn) forget(st *h2Stream) {
+	cc.mu.Lock()
+	delete(cc.streams, st.id)
+	cc.cond.Broadcast()
+	//收到GOAWAY后连接已不在t.h2中,CloseIdleConnections无法关闭它,最后一个流结束时在这里关闭
+	drained := cc.goAway && len(cc.streams) == 0
+	cc.mu.Unlock()
+	if drained {
+		cc.closeIfIdle()
+	}
+	//等待连接数名额的请求可以改用这个连接
+	if cc.t.maxConnsPerHost > 0 {
+		cc.t.mu.Lock()
+		cc.t.notifyLocked(cc.key)
+		cc.t.mu.Unlock()
+	}
+}
+
+//读取循环,处理服务器发来的全部帧
+func (cc *h2Conn) readLoop() {
+	pushing := false //正在接收被拒绝的PUSH_PROMISE的后续CONTINUATION帧
+	for {
+		f, err := cc.fr.ReadFrame()
+		if err != nil {
+			cc.fail(err)
+			return
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			var tableSize uint32
+			cc.mu.Lock()
+			f.ForeachSetting(func(s http2.Setting) error {
+				switch s.ID {
+				case http2.SettingMaxFrameSize:
+					cc.maxFrameSize = s.Val
+				case http2.SettingMaxConcurrentStreams:
+					cc.maxStreams = s.Val
+				case http2.SettingInitialWindowSize:
+					delta := int32(s.Val) - cc.peerWindow
+					for _, st := range cc.streams {
+						st.sendWindow += delta
+					}
+					cc.peerWindow = int32(s.Val)
+				case http2.SettingHeaderTableSize:
+					tableSize = s.Val + 1
+				}
+				return nil
+			})
+			cc.cond.Broadcast()
+			cc.mu.Unlock()
+			err = cc.write(func() error {
+				//cc.wmu不能在持有cc.mu时获取
+				if tableSize > 0 {
+					cc.henc.SetMaxDynamicTableSizeLimit(tableSize - 1)
+				}
+				return cc.fr.WriteSettingsAck()
+			})
+		case *http2.MetaHeadersFrame:
+			st := cc.stream(f.StreamID)
+			if st == nil {
+				continue
+			}
+			st.onHeaders(f)
+			if f.StreamEnded() {
+				st.endBody(io.EOF)
+			}
+		case *http2.DataFrame:
+			//连接级别的窗口收到即归还,每个流的窗口在调用方读取后归还
+			cc.mu.Lock()
+			cc.connUnacked += int32(f.Length)
+			var inc int32
+			if cc.connUnacked >= cc.connRecvLimit/2 {
+				inc, cc.connUnacked = cc.connUnacked, 0
+			}
+			cc.mu.Unlock()
+			if inc > 0 {
+				if err = cc.write(func() error { return cc.fr.WriteWindowUpdate(0, uint32(inc)) }); err != nil {
+					break
+				}
+			}
+			st := cc.stream(f.StreamID)
+			if st == nil {
+				continue
+			}
+			st.onData(f.Data(), int32(f.Length))
+			if f.StreamEnded() {
+				st.endBody(io.EOF)
+			}
+		case *http2.WindowUpdateFrame:
+			cc.mu.Lock()
+			if f.StreamID == 0 {
+				cc.sendWindow += int32(f.Increment)
+			} else if st := cc.streams[f.StreamID]; st != nil {
+				st.sendWindow += int32(f.Increment)
+			}
+			cc.cond.Broadcast()
+			cc.mu.Unlock()
+		case *http2.RSTStreamFrame:
+			if st := cc.stream(f.StreamID); st != nil {
+				st.finish(http2.StreamError{StreamID: f.StreamID, Code: f.ErrCode})
+			}
+		case *http2.PushPromiseFrame:
+			//不接受服务器推送,Firefox等指纹没有通过SETTINGS关闭推送,只能逐个拒绝
+			promised := f.PromiseID
+			pushing = !f.HeadersEnded()
+			err = cc.skipHeaderBlock(f.HeaderBlockFragment(), f.HeadersEnded())
+			if err == nil {
+				err = cc.write(func() error { return cc.fr.WriteRSTStream(promised, http2.ErrCodeRefusedStream) })
+			}
+		case *http2.ContinuationFrame:
+			if !pushing {
+				err = http2.ConnectionError(http2.ErrCodeProtocol)
+				break
+			}
+			pushing = !f.HeadersEnded()
+			err = cc.skipHeaderBlock(f.HeaderBlockFragment(), f.HeadersEnded())
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				data := f.Data
+				err = cc.write(func() error { return cc.fr.WritePing(true, data) })
+			}
+		case *http2.GoAwayFrame:
+			//LastStreamID之后的请求没有被处理,可以在新连接上重新发送
+			cc.mu.Lock()
+			cc.goAway = true
+			var unprocessed []*h2Stream
+			for id, st := range cc.streams {
+				if id > f.LastStreamID {
+					unprocessed = append(unprocessed, st)
+				}
+			}
+			cc.mu.Unlock()
+			for _, st := range unprocessed {
+				st.finish(errH2Unprocessed)
+			}
+			cc.t.mu.Lock()
+			cc.t.removeH2Locked(cc)
+			cc.t.mu.Unlock()
+			//没有进行中的请求时立即关闭,否则由最后一个结束的流关闭
+			cc.closeIfIdle()
+		}
+		if err != nil {
+			cc.fail(err)
+			return
+		}
+	}
+}
+
+//解码并丢弃一段头部,以保持HPACK动态表与服务器同步
+func (cc *h2Conn) skipHeaderBlock(fragment []byte, end bool) error {
+	hdec := cc.fr.ReadMetaHeaders
+	hdec.SetEmitFunc(func(hpack.HeaderField) {})
+	if _, err := hdec.Write(fragment); err != nil {
+		return http2.ConnectionError(http2.ErrCodeCompression)
+	}
+	if end {
+		if err := hdec.Close(); err != nil {
+			return http2.ConnectionError(http2.ErrCodeCompression)
+		}
+	}
+	return nil
+}
+
+type h2Result struct {
+	resp *http.Response
+	err  error
+}
+
+//HTTP/2连接上的一个流,同时作为响应的Body
+type h2Stream struct {
+	cc         *h2Conn
+	id         uint32
+	req        *http.Request
+	sendWindow int32 //由cc.mu保护
+	resc       chan h2Result
+	done       chan struct{} //流结束时关闭
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	gotHeaders  bool
+	buf         bytes.Buffer
+	bodyErr     error //为io.EOF表示响应内容已全部收到
+	recvUnacked int32
+	ended       bool
+}
+
+//收到响应头,1xx响应跳过,已收到响应头时为trailer,忽略
+func (st *h2Stream) onHeaders(f *http2.MetaHeadersFrame) {
+	status, err := strconv.Atoi(f.PseudoValue("status"))
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.gotHeaders {
+		return
+	}
+	if err != nil {
+		st.resc <- h2Result{err: fmt.Errorf("http2: invalid :status %q", f.PseudoValue("status"))}
+		st.gotHeaders = true
+		return
+	}
+	if status >= 100 && status < 200 {
+		return
+	}
+	st.gotHeaders = true
+	header := make(http.Header)
+	for _, hf := range f.RegularFields() {
+		header.Add(http.CanonicalHeaderKey(hf.Name), hf.Value)
+	}
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		Body:          st,
+		ContentLength: -1,
+		Request:       st.req,
+		TLS:           st.cc.tls,
+	}
+	if cl := header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			resp.ContentLength = n
+		}
+	}
+	st.resc <- h2Result{resp: resp}
+}
+
+func (st *h2Stream) onData(data []byte, flow int32) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.bodyErr != nil {
+		return
+	}
+	st.buf.Write(data)
+	//padding部分不会被读取,直接计入待归还的窗口
+	st.recvUnacked += flow - int32(len(data))
+	st.cond.Broadcast()
+}
+
+//响应内容结束,err为io.EOF表示正常结束
+func (st *h2Stream) endBody(err error) {
+	st.mu.Lock()
+	if st.bodyErr == nil {
+		st.bodyErr = err
+	}
+	st.cond.Broadcast()
+	st.mu.Unlock()
+	st.end()
+}
+
+//流异常结束,还没有收到响应头时作为请求的结果返回
+func (st *h2Stream) finish(err error) {
+	st.mu.Lock()
+	if !st.gotHeaders {
+		st.gotHeaders = true
+		st.resc <- h2Result{err: err}
+	}
+	if st.bodyErr == nil {
+		st.bodyErr = err
+	}
+	st.cond.Broadcast()
+	st.mu.Unlock()
+	st.end()
+}
+
+//从连接中移除这个流
+func (st *h2Stream) end() {
+	st.mu.Lock()
+	if st.ended {
+		st.mu.Unlock()
+		return
+	}
+	st.ended = true
+	close(st.done)
+	st.mu.Unlock()
+	st.cc.forget(st)
+}
+
+//流已结束时不能再发送body,返回结束的原因
+func (st *h2Stream) sendErr() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.bodyErr
+}
+
+//调用方取消请求或提前关闭Body时,通知服务器重置这个流
+func (st *h2Stream) cancel(err error) {
+	st.mu.Lock()
+	ended := st.ended
+	st.mu.Unlock()
+	if !ended {
+		st.cc.write(func() error { return st.cc.fr.WriteRSTStream(st.id, http2.ErrCodeCancel) })
+	}
+	st.finish(err)
+}
+
+//发送请求的body,发送失败时重置这个流
+func (st *h2Stream) writeBody(body io.ReadCloser) {
+	defer body.Close()
+	cc := st.cc
+	buf := make([]byte, 16384)
+	for {
+		n, rerr := body.Read(buf)
+		data := buf[:n]
+		for len(data) > 0 {
+			k, err := cc.awaitSendWindow(st, len(data))
+			if err != nil {
+				return
+			}
+			chunk := data[:k]
+			if err := cc.write(func() error { return cc.fr.WriteData(st.id, false, chunk) }); err != nil {
+				cc.fail(err)
+				return
+			}
+			data = data[k:]
+		}
+		if rerr == io.EOF {
+			if err := cc.write(func() error { return cc.fr.WriteData(st.id, true, nil) }); err != nil {
+				cc.fail(err)
+			}
+			return
+		}
+		if rerr != nil {
+			st.cancel(rerr)
+			return
+		}
+	}
+}
+
+func (st *h2Stream) Read(p []byte) (int, error) {
+	st.mu.Lock()
+	for st.buf.Len() == 0 && st.bodyErr == nil {
+		st.cond.Wait()
+	}
+	if st.buf.Len() == 0 {
+		err := st.bodyErr
+		st.mu.Unlock()
+		return 0, err
+	}
+	n, _ := st.buf.Read(p)
+	st.recvUnacked += int32(n)
+	var inc int32
+	if st.bodyErr == nil && st.recvUnacked >= st.cc.streamWindow/2 {
+		inc, st.recvUnacked = st.recvUnacked, 0
+	}
+	st.mu.Unlock()
+	if inc > 0 {
+		st.cc.write(func() error { return st.cc.fr.WriteWindowUpdate(st.id, uint32(inc)) })
+	}
+	return n, nil
+}
+
+//未读完就关闭时重置这个流,以免服务器继续发送
+func (st *h2Stream) Close() error {
+	st.mu.Lock()
+	complete := st.bodyErr == io.EOF
+	st.mu.Unlock()
+	if !complete {
+		st.cancel(errH2BodyClosed)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+//测试用的自签名证书,取自httptest
+func testTLSConfig(t *testing.T) *tls.Config {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	cfg := srv.TLS.Clone()
+	srv.Close()
+	return cfg
+}
+
+//记录客户端发来的SETTINGS,WINDOW_UPDATE及HEADERS的HTTP/2服务器
+type h2CaptureServer struct {
+	ln     net.Listener
+	conns  int64
+	goAway bool //收到HEADERS后回复GOAWAY而不是响应
+	//响应时同时发送GOAWAY,drainBefore为true时在响应内容之前发送,否则在响应结束之后发送
+	drain       bool
+	drainBefore bool
+	closed      int64 //客户端关闭的连接数
+	mu     sync.Mutex
+	//第一个连接的SETTINGS及连接级别的WINDOW_UPDATE
+	settings  []http2.Setting
+	increment uint32
+	//最近一个请求的全部头部,包括伪头部,按线路上的顺序
+	fields []hpack.HeaderField
+}
+
+func newH2CaptureServer(t *testing.T, goAway bool) *h2CaptureServer {
+	cfg := testTLSConfig(t)
+	cfg.NextProtos = []string{"h2"}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &h2CaptureServer{ln: ln, goAway: goAway}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, atomic.AddInt64(&s.conns, 1) == 1)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *h2CaptureServer) URL() string {
+	return "https://" + s.ln.Addr().String()
+}
+
+func (s *h2CaptureServer) serve(c net.Conn, first bool) {
+	defer c.Close()
+	defer atomic.AddInt64(&s.closed, 1)
+	br := bufio.NewReader(c)
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(br, preface); err != nil || string(preface) != http2.ClientPreface {
+		return
+	}
+	fr := http2.NewFramer(c, br)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	var enc strings.Builder
+	henc := hpack.NewEncoder(&enc)
+	if err := fr.WriteSettings(); err != nil {
+		return
+	}
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			if first {
+				s.mu.Lock()
+				s.settings = nil
+				f.ForeachSetting(func(st http2.Setting) error {
+					s.settings = append(s.settings, st)
+					return nil
+				})
+				s.mu.Unlock()
+			}
+			fr.WriteSettingsAck()
+		case *http2.WindowUpdateFrame:
+			if first && f.StreamID == 0 {
+				s.mu.Lock()
+				if s.increment == 0 {
+					s.increment = f.Increment
+				}
+				s.mu.Unlock()
+			}
+		case *http2.MetaHeadersFrame:
+			if s.goAway {
+				fr.WriteGoAway(0, http2.ErrCodeNo, nil)
+				continue
+			}
+			s.mu.Lock()
+			s.fields = f.Fields
+			s.mu.Unlock()
+			enc.Reset()
+			henc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+			henc.WriteField(hpack.HeaderField{Name: "content-type", Value: "text/plain"})
+			fr.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      f.StreamID,
+				BlockFragment: []byte(enc.String()),
+				EndHeaders:    true,
+			})
+			if s.drain && s.drainBefore {
+				fr.WriteGoAway(f.StreamID, http2.ErrCodeNo, nil)
+			}
+			fr.WriteData(f.StreamID, true, []byte("h2"))
+			if s.drain && !s.drainBefore {
+				fr.WriteGoAway(f.StreamID, http2.ErrCodeNo, nil)
+			}
+		}
+	}
+}
+
+//最近一个请求的头部名称,按线路上的顺序
+func (s *h2CaptureServer) lastNames() (pseudo, names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.fields {
+		if f.IsPseudo() {
+			pseudo = append(pseudo, f.Name)
+		} else {
+			names = append(names, f.Name)
+		}
+	}
+	return pseudo, names
+}
+
+func TestH2HeaderOrder(t *testing.T) {
+	srv := newH2CaptureServer(t, false)
+	ga := New(
+		WithHeaders(map[string]string{"User-Agent": "gather-test", "X-Second": "2", "x-first": "1", "Accept": "*/*"}),
+		WithHeaderOrder("x-first", "User-Agent", "X-Second", "Accept"),
+		WithPseudoHeaderOrder(":method", ":path", ":authority", ":scheme"),
+		WithOrderedHeaders(true),
+	)
+	defer ga.Close()
+	html, _, err := ga.Get(srv.URL()+"/a", "")
+	if err != nil || html != "h2" {
+		t.Fatal(html, err)
+	}
+	pseudo, names := srv.lastNames()
+	if got := strings.Join(pseudo, ","); got != ":method,:path,:authority,:scheme" {
+		t.Fatalf("伪头部顺序有误: %s", got)
+	}
+	//HTTP/2的名称全部为小写,Host由:authority代替
+	want := []string{"x-first", "user-agent", "x-second", "accept"}
+	if len(names) < len(want) || strings.Join(names[:len(want)], ",") != strings.Join(want, ",") {
+		t.Fatalf("Header顺序有误, 期望以%v开头, 实际%v", want, names)
+	}
+	for _, n := range names {
+		if n != strings.ToLower(n) || n == "host" {
+			t.Fatalf("HTTP/2的Header名称有误: %v", names)
+		}
+	}
+
+	//伪头部不完整时按默认顺序补全
+	ga.PseudoHeaderOrder = []string{":path"}
+	if _, _, err := ga.Get(srv.URL()+"/b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if pseudo, _ := srv.lastNames(); strings.Join(pseudo, ",") != ":path,:method,:authority,:scheme" {
+		t.Fatalf("补全后的伪头部顺序有误: %v", pseudo)
+	}
+}
+
+//并发请求共用一个连接,stream ID须按发送顺序递增,否则服务器会关闭连接
+func TestH2Concurrent(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	ga := New(WithOrderedHeaders(true))
+	defer ga.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			html, _, err := ga.Get(srv.URL, "")
+			if err != nil || html != "HTTP/2.0" {
+				t.Error(html, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+//同时建立的多个HTTP/2连接在Close后全部关闭
+func TestH2CloseAllConns(t *testing.T) {
+	var active int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		switch s {
+		case http.StateNew:
+			atomic.AddInt64(&active, 1)
+		case http.StateClosed, http.StateHijacked:
+			atomic.AddInt64(&active, -1)
+		}
+	}
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	ga := New(WithOrderedHeaders(true))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ga.Get(srv.URL, "")
+		}()
+	}
+	wg.Wait()
+	ga.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt64(&active) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&active); n != 0 {
+		t.Fatalf("Close后仍有%d个连接未关闭", n)
+	}
+}
+
+//服务器对每个请求都回复GOAWAY时,重试maxConnRetries次后返回错误
+func TestH2GoAwayRetryLimit(t *testing.T) {
+	srv := newH2CaptureServer(t, true)
+	ga := New(WithOrderedHeaders(true), WithTimeout(5*time.Second))
+	defer ga.Close()
+	if _, _, err := ga.Get(srv.URL(), ""); err == nil {
+		t.Fatal("期望返回错误")
+	}
+	if n := atomic.LoadInt64(&srv.conns); n != maxConnRetries+1 {
+		t.Fatalf("期望建立%d个连接, 实际%d", maxConnRetries+1, n)
+	}
+}
+
+//收到GOAWAY后,连接在最后一个流结束时关闭,没有进行中的请求时立即关闭
+func TestH2GoAwayClosesDrainedConn(t *testing.T) {
+	for _, before := range []bool{true, false} {
+		srv := newH2CaptureServer(t, false)
+		srv.drain, srv.drainBefore = true, before
+		ga := New(WithOrderedHeaders(true))
+		html, _, err := ga.Get(srv.URL(), "")
+		if err != nil || html != "h2" {
+			t.Fatal(before, html, err)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for atomic.LoadInt64(&srv.closed) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if atomic.LoadInt64(&srv.closed) != 1 {
+			t.Fatalf("drainBefore=%v: 收到GOAWAY后连接未关闭", before)
+		}
+		ga.Close()
+	}
+}
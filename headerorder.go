@@ -0,0 +1,157 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//HTTP/2的伪头部,按Go标准库的顺序
+var defaultPseudoHeaderOrder = []string{":method", ":authority", ":scheme", ":path"}
+
+/*
+一次请求的Request Headers在线路上的顺序及大小写,通过请求的context传递给有序传输层
+names中的名称即实际发送时的写法,如"sec-ch-ua","User-Agent";不在names中的Header按字母顺序排在最后
+*/
+type headerOrder struct {
+	names  []string          //Request Headers的顺序
+	pseudo []string          //HTTP/2伪头部的顺序
+	casing map[string]string //规范化的名称 -> 实际发送时的写法
+}
+
+type headerOrderKey struct{}
+
+//由GatherStruct的设置生成本次请求的Header顺序,没有设置HeaderOrder时返回nil
+func (g *GatherStruct) headerOrder() *headerOrder {
+	if len(g.HeaderOrder) == 0 && len(g.PseudoHeaderOrder) == 0 {
+		return nil
+	}
+	o := &headerOrder{names: g.HeaderOrder, pseudo: g.PseudoHeaderOrder, casing: make(map[string]string)}
+	//默认Request Headers中的写法优先级低于HeaderOrder中的写法
+	g.safeHeaders.Range(func(k, _ interface{}) bool {
+		name := k.(string)
+		o.casing[http.CanonicalHeaderKey(name)] = name
+		return true
+	})
+	for _, name := range g.HeaderOrder {
+		o.casing[http.CanonicalHeaderKey(name)] = name
+	}
+	return o
+}
+
+//在req上附加Header顺序,order为nil时原样返回
+func withHeaderOrder(req *http.Request, order *headerOrder) *http.Request {
+	if order == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), headerOrderKey{}, order))
+}
+
+func headerOrderFromContext(ctx context.Context) *headerOrder {
+	order, _ := ctx.Value(headerOrderKey{}).(*headerOrder)
+	return order
+}
+
+//一个将要发送的Header
+type headerField struct {
+	name  string
+	value string
+}
+
+/*
+按顺序排列将要发送的Request Headers,extra为传输层额外添加的Host,Content-Length等
+lower为true时名称全部转为小写,用于HTTP/2
+*/
+func (o *headerOrder) fields(h http.Header, extra []headerField, lower bool) []headerField {
+	all := make(map[string][]string, len(h)+len(extra))
+	for k, vv := range h {
+		all[http.CanonicalHeaderKey(k)] = vv
+	}
+	for _, f := range extra {
+		all[http.CanonicalHeaderKey(f.name)] = []string{f.value}
+	}
+	var names []string
+	var casing map[string]string
+	if o != nil {
+		names, casing = o.names, o.casing
+	}
+	result := make([]headerField, 0, len(all))
+	add := func(key string) {
+		name := key
+		if c, exist := casing[key]; exist {
+			name = c
+		}
+		if lower {
+			name = strings.ToLower(name)
+		}
+		for _, v := range all[key] {
+			result = append(result, headerField{name, v})
+		}
+		delete(all, key)
+	}
+	//没有指定顺序时,与浏览器一样Host排在最前面
+	if len(names) == 0 {
+		add("Host")
+	}
+	for _, name := range names {
+		add(http.CanonicalHeaderKey(name))
+	}
+	rest := make([]string, 0, len(all))
+	for key := range all {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		add(key)
+	}
+	return result
+}
+
+//HTTP/2伪头部的顺序,未设置或不完整时补全
+func (o *headerOrder) pseudoOrder() []string {
+	if o == nil || len(o.pseudo) == 0 {
+		return defaultPseudoHeaderOrder
+	}
+	order := append([]string(nil), o.pseudo...)
+	for _, p := range defaultPseudoHeaderOrder {
+		found := false
+		for _, q := range order {
+			if q == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			order = append(order, p)
+		}
+	}
+	return order
+}
+
+//请求body的长度,-1表示未知,与标准库的判断方式一致
+func outgoingLength(req *http.Request) int64 {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0
+	}
+	if req.ContentLength != 0 {
+		return req.ContentLength
+	}
+	return -1
+}
+
+//传输层需要额外添加的Content-Length,body为空的POST,PUT,PATCH也要带上Content-Length: 0
+func contentLengthField(req *http.Request) (headerField, bool) {
+	n := outgoingLength(req)
+	if n > 0 || (n == 0 && (req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH")) {
+		return headerField{"Content-Length", strconv.FormatInt(n, 10)}, true
+	}
+	return headerField{}, false
+}
@@ -6,6 +6,10 @@
 //模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
 package gather
 
+import (
+	"context"
+)
+
 /*
 GET方式获取数据,自动继承先前的cookies
 URL:指待抓取的URL
@@ -32,13 +36,39 @@ ga:= NewGather("chrome", false)
 cookies:=`SINAGLOBAL=8868584542946.604.1509350660873;??????????; YF-Page-G0=b9385a03a044baf8db46b84f3ff125a0`
 html, redirectURL, err := ga.MethodUtil("OPTIONS","https://weibo.com/xxxxxx",cookies, "")
 */
-//GET方式获取数据,手动设置Cookie,Cookie留空则自动继承上次抓取时使用的Cookie
+//GET方式获取数据,手动设置Cookie,cookies只对本次抓取生效,之后的抓取不会再发送
 func (g *GatherStruct) MethodUtil(method, URL, refererURL, cookies string) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
-	req, err := g.newHttpRequest(method, URL, refererURL, cookies, nil)
+	return htmlResult(g.MethodResponse(method, URL, refererURL, cookies))
+}
+
+/*
+以任意method获取数据,返回包含状态码,响应头等信息的完整Response
+参数含义与MethodUtil相同,cookies留空则自动继承先前的cookies
+
+例:
+ga := NewGather("chrome", false)
+resp, err := ga.MethodResponse("HEAD", "https://www.baidu.com/", "", "")
+fmt.Println(resp.StatusCode, resp.Header)
+*/
+func (g *GatherStruct) MethodResponse(method, URL, refererURL, cookies string) (*Response, error) {
+	return g.MethodResponseCtx(context.Background(), method, URL, refererURL, cookies)
+}
+
+//以任意method获取数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取,其余参数含义与Method相同
+func (g *GatherStruct) MethodCtx(ctx context.Context, method, URL, refererURL string) (html, redirectURL string, err error) {
+	return g.MethodUtilCtx(ctx, method, URL, refererURL, "")
+}
+
+//以任意method获取数据,手动设置Cookie,ctx取消或超时后立即中止抓取,其余参数含义与MethodUtil相同
+func (g *GatherStruct) MethodUtilCtx(ctx context.Context, method, URL, refererURL, cookies string) (html, redirectURL string, err error) {
+	return htmlResult(g.MethodResponseCtx(ctx, method, URL, refererURL, cookies))
+}
+
+//以任意method获取数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与MethodResponse相同
+func (g *GatherStruct) MethodResponseCtx(ctx context.Context, method, URL, refererURL, cookies string) (*Response, error) {
+	req, err := g.newHttpRequest(ctx, method, URL, nil, "", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
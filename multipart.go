@@ -0,0 +1,299 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+multipart/form-data 上传的一个文件,Reader,Path,Content按顺序只使用第一个不为空的
+Path指定的文件在发送时才打开,与Reader一样边读边发送,不会一次性读入内存
+Reader只能读取一次,因此使用Reader的上传不会重试,Reader由调用方自行关闭
+*/
+type MultipartFile struct {
+	FieldName   string    //表单中的字段名,同一字段名可以出现多次
+	FileName    string    //上传的文件名
+	ContentType string    //为空时按FileName的扩展名判断,无法判断时为application/octet-stream
+	Content     []byte    //文件内容
+	Reader      io.Reader //流式读取的文件内容
+	Path        string    //本地文件路径
+}
+
+//由本地文件生成待上传的文件,文件名及Content-Type由路径得到
+func MultipartFileFromPath(fieldName, path string) (*MultipartFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s不是普通文件", path)
+	}
+	return &MultipartFile{FieldName: fieldName, FileName: filepath.Base(path), Path: path}, nil
+}
+
+//由io.Reader生成待上传的文件,Content-Type由fileName的扩展名判断,也可在返回后自行设置
+func MultipartFileFromReader(fieldName, fileName string, r io.Reader) *MultipartFile {
+	return &MultipartFile{FieldName: fieldName, FileName: fileName, Reader: r}
+}
+
+//能否重新读取文件内容,以便重试时再次发送
+func (f *MultipartFile) replayable() bool {
+	return f.Reader == nil
+}
+
+func (f *MultipartFile) contentType() string {
+	if f.ContentType != "" {
+		return f.ContentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(f.FileName)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+//把文件内容写入w
+func (f *MultipartFile) writeTo(w io.Writer) error {
+	switch {
+	case f.Reader != nil:
+		_, err := io.Copy(w, f.Reader)
+		return err
+	case f.Path != "":
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	default:
+		_, err := w.Write(f.Content)
+		return err
+	}
+}
+
+//multipart/form-data 中的一个普通文本字段
+type MultipartField struct {
+	Name  string
+	Value string
+}
+
+/*
+multipart/form-data 表单,字段及文件按添加的顺序发送,同一字段名可以添加多次
+Boundary为空时随机生成,否则须符合RFC 2046的要求,如浏览器中常见的----WebKitFormBoundaryTP3TumA8yjBZCv2R
+
+例:
+form := &gather.MultipartForm{}
+form.AddField("title", "测试")
+form.AddField("tag", "a")
+form.AddField("tag", "b")
+file, err := gather.MultipartFileFromPath("pic", "D:/1.jpg")
+form.AddFile(file)
+*/
+type MultipartForm struct {
+	Boundary string
+	Fields   []MultipartField
+	Files    []*MultipartFile
+}
+
+//添加一个普通文本字段
+func (f *MultipartForm) AddField(name, value string) {
+	f.Fields = append(f.Fields, MultipartField{Name: name, Value: value})
+}
+
+//添加一个文件
+func (f *MultipartForm) AddFile(file *MultipartFile) {
+	f.Files = append(f.Files, file)
+}
+
+//按RFC 7578生成完整的表单内容,写入w
+func (f *MultipartForm) writeTo(w *multipart.Writer) error {
+	for _, field := range f.Fields {
+		if err := w.WriteField(field.Name, field.Value); err != nil {
+			return err
+		}
+	}
+	for _, file := range f.Files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(file.FieldName), quoteEscaper.Replace(file.FileName)))
+		h.Set("Content-Type", file.contentType())
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if err := file.writeTo(part); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+//与mime/multipart中的转义方式一致
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+//能否重新生成表单内容,以便重试时再次发送
+func (f *MultipartForm) replayable() bool {
+	for _, file := range f.Files {
+		if !file.replayable() {
+			return false
+		}
+	}
+	return true
+}
+
+//生成请求的body及Content-Type
+func (f *MultipartForm) body() (io.ReadCloser, string, error) {
+	boundary := f.Boundary
+	if boundary == "" {
+		boundary = multipart.NewWriter(nil).Boundary()
+	} else if err := multipart.NewWriter(nil).SetBoundary(boundary); err != nil {
+		return nil, "", err
+	}
+	pr, pw := io.Pipe()
+	return &multipartBody{form: f, boundary: boundary, pr: pr, pw: pw}, "multipart/form-data; boundary=" + boundary, nil
+}
+
+//边生成边发送的表单内容,第一次读取时才开始生成,请求未发送就关闭时不会留下goroutine
+type multipartBody struct {
+	form     *MultipartForm
+	boundary string
+	once     sync.Once
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			w := multipart.NewWriter(b.pw)
+			w.SetBoundary(b.boundary)
+			b.pw.CloseWithError(b.form.writeTo(w))
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+//关闭后正在生成内容的goroutine会因写入失败而退出
+func (b *multipartBody) Close() error {
+	return b.pr.Close()
+}
+
+/*
+以multipart/form-data的方式post数据,自动继承先前的cookies
+URL:指待抓取的URL
+refererURL:上一次访问的URL。某些防抓取比较严格的网站会对上次访问的页面URL进行验证
+redirectURL:最终实际访问到内容的URL。因为有时候会碰到301跳转等情况，最终访问的URL并非输入的URL
+form:待上传的字段及文件
+
+例:
+ga := gather.NewGather("chrome", false)
+form := &gather.MultipartForm{}
+form.AddField("user", "ydg")
+form.AddFile(gather.MultipartFileFromReader("pic", "1.jpg", bytes.NewReader(picBytes)))
+html, redirectURL, err := ga.PostMultipart("https://weibo.com/xxxxx", "", form)
+*/
+func (g *GatherStruct) PostMultipart(URL, refererURL string, form *MultipartForm) (html, redirectURL string, err error) {
+	return g.PostMultipartUtil(URL, refererURL, "", form)
+}
+
+//以multipart/form-data的方式post数据,手动增加cookies,其余参数含义与PostMultipart相同
+func (g *GatherStruct) PostMultipartUtil(URL, refererURL, cookies string, form *MultipartForm) (html, redirectURL string, err error) {
+	return htmlResult(g.PostMultipartResponse(URL, refererURL, cookies, form))
+}
+
+//以multipart/form-data的方式post数据,返回完整的Response,参数含义与PostMultipartUtil相同
+func (g *GatherStruct) PostMultipartResponse(URL, refererURL, cookies string, form *MultipartForm) (*Response, error) {
+	return g.PostMultipartResponseCtx(context.Background(), URL, refererURL, cookies, form)
+}
+
+//以multipart/form-data的方式post数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostMultipart相同
+func (g *GatherStruct) PostMultipartCtx(ctx context.Context, URL, refererURL string, form *MultipartForm) (html, redirectURL string, err error) {
+	return g.PostMultipartUtilCtx(ctx, URL, refererURL, "", form)
+}
+
+//以multipart/form-data的方式post数据,手动增加cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostMultipartUtil相同
+func (g *GatherStruct) PostMultipartUtilCtx(ctx context.Context, URL, refererURL, cookies string, form *MultipartForm) (html, redirectURL string, err error) {
+	return htmlResult(g.PostMultipartResponseCtx(ctx, URL, refererURL, cookies, form))
+}
+
+//以multipart/form-data的方式post数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与PostMultipartResponse相同
+func (g *GatherStruct) PostMultipartResponseCtx(ctx context.Context, URL, refererURL, cookies string, form *MultipartForm) (*Response, error) {
+	if form == nil {
+		return nil, errors.New("form不能为nil")
+	}
+	body, contentType, err := form.body()
+	if err != nil {
+		return nil, err
+	}
+	//Content-Type中带有本次的boundary,必须覆盖默认Request Headers中的Content-Type
+	opts := refererAndCookies(refererURL, cookies)
+	opts.ContentType = contentType
+	req, err := g.newHttpRequest(ctx, "POST", URL, body, "", opts)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if form.replayable() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			b, _, err := form.body()
+			return b, err
+		}
+	}
+	return g.request(req, nil)
+}
+
+//multipart/form-data方式POST数据,手动增加cookies,参数含义与PostMultipartformDataUtil相同
+func (g *GatherStruct) PostMultipartformData(URL, refererURL, cookies, boundary string, postValueMap map[string]string, postFileMap map[string]MultipartFile) (html, redirectURL string, err error) {
+	return g.PostMultipartformDataUtil(URL, refererURL, cookies, boundary, postValueMap, postFileMap)
+}
+
+/*
+multipart/form-data方式POST数据,cookies留空则自动继承先前的cookies
+boundary指post“分割边界”,即Content-Type中boundary=之后的部分,留空时随机生成
+postValueMap指post的普通文本,只包含name和value
+postFileMap指上传的文件,键为字段名,其中的FieldName不起作用
+字段按字段名排序后发送,需要同一字段名出现多次或指定顺序时请使用PostMultipart
+*/
+func (g *GatherStruct) PostMultipartformDataUtil(URL, refererURL, cookies, boundary string, postValueMap map[string]string, postFileMap map[string]MultipartFile) (html, redirectURL string, err error) {
+	return g.PostMultipartformDataUtilCtx(context.Background(), URL, refererURL, cookies, boundary, postValueMap, postFileMap)
+}
+
+//multipart/form-data方式POST数据,ctx取消或超时后立即中止抓取,其余参数含义与PostMultipartformDataUtil相同
+func (g *GatherStruct) PostMultipartformDataUtilCtx(ctx context.Context, URL, refererURL, cookies, boundary string, postValueMap map[string]string, postFileMap map[string]MultipartFile) (html, redirectURL string, err error) {
+	form := &MultipartForm{Boundary: boundary}
+	names := make([]string, 0, len(postValueMap))
+	for name := range postValueMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		form.AddField(name, postValueMap[name])
+	}
+	names = names[:0]
+	for name := range postFileMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := postFileMap[name]
+		file.FieldName = name
+		form.AddFile(&file)
+	}
+	return g.PostMultipartUtilCtx(ctx, URL, refererURL, cookies, form)
+}
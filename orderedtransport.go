@@ -0,0 +1,692 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+//请求没有被服务器处理时,最多换新连接重新发送的次数,与x/net/http2一致
+const maxConnRetries = 6
+
+//复用的空闲连接已被服务器关闭,可以换一个新连接重新发送
+var errStaleConn = errors.New("connection closed by server before response")
+
+/*
+有序传输层,与http.Transport的区别是严格按请求context中的headerOrder发送Request Headers
+HTTP/1.1按指定的顺序及大小写发送;HTTPS通过ALPN协商,服务器支持时使用HTTP/2,并按指定的伪头部顺序发送
+支持HTTP,HTTPS及SOCKS4/4a/5/5h代理,不支持Expect: 100-continue及HTTP/1.1管线化
+*/
+type orderedTransport struct {
+	proxyURL    *url.URL
+	proxyErr    error
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	dialer      *connDialer
+	fp          *fingerprint //TLS及HTTP/2指纹,为nil时使用Go标准库的TLS
+	proxyHeader http.Header  //通过HTTP代理建立隧道时,CONNECT请求中额外发送的Header
+	//连接数及空闲连接的设置,含义与http.Transport中的同名字段相同
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+
+	mu       sync.Mutex
+	idle     map[string][]*h1Conn     //代理|scheme://host:port -> 空闲的HTTP/1.1连接
+	idleN    int                      //全部空闲连接数
+	h2       map[string][]*h2Conn     //代理|scheme://host:port -> 可以发送新请求的HTTP/2连接,同时建立的连接都保留在这里以便关闭
+	conns    map[string]int           //已建立的连接数,只在maxConnsPerHost大于0时统计
+	connWait map[string]chan struct{} //连接数达到上限时等待的请求,连接关闭,放回空闲或可以复用时关闭
+}
+
+//按tc创建一个有序传输层,fp为nil时使用Go标准库的TLS
+func newOrderedTransport(tc transportConfig, fp *fingerprint) *orderedTransport {
+	tlsConfig := tc.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: true} //忽略认证
+	}
+	t := &orderedTransport{
+		tlsConfig:           tlsConfig,
+		dialTimeout:         tc.dialTimeout,
+		dialer:              newConnDialer(tc),
+		fp:                  fp,
+		proxyHeader:         tc.proxyHeader,
+		maxIdleConns:        tc.maxIdleConns,
+		maxIdleConnsPerHost: tc.maxIdleConnsPerHost,
+		maxConnsPerHost:     tc.maxConnsPerHost,
+		idleConnTimeout:     tc.idleConnTimeout,
+		idle:                make(map[string][]*h1Conn),
+		h2:                  make(map[string][]*h2Conn),
+		conns:               make(map[string]int),
+		connWait:            make(map[string]chan struct{}),
+	}
+	if tc.proxyURL != "" {
+		t.proxyURL, t.proxyErr = parseProxyURL(tc.proxyURL)
+	}
+	return t
+}
+
+func (t *orderedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	if t.proxyErr != nil {
+		closeRequestBody(req)
+		return nil, t.proxyErr
+	}
+	//与标准库一样,在建立连接及写出任何内容之前拒绝不合法的Method及Request Headers,以免注入额外的Header或请求
+	if err := validateRequest(req); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	//本次请求指定的代理优先,不同代理的连接不能混用
+	proxy := proxyFromContext(req.Context(), t.proxyURL)
+	key := req.URL.Scheme + "://" + canonicalAddr(req.URL)
+	if proxy != nil {
+		key = proxy.String() + "|" + key
+	}
+	for retry := 0; ; retry++ {
+		resp, err := t.roundTripOnce(req, key, proxy)
+		if err == nil {
+			return resp, nil
+		}
+		//请求没有被服务器处理时,换一个连接重新发送,body无法重新读取或重发次数用完时除外
+		if err != errStaleConn && err != errH2Unprocessed {
+			return nil, err
+		}
+		if retry >= maxConnRetries {
+			closeRequestBody(req)
+			return nil, err
+		}
+		closeRequestBody(req)
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, berr
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (t *orderedTransport) roundTripOnce(req *http.Request, key string, proxy *url.URL) (*http.Response, error) {
+	for {
+		if cc := t.getH2(key); cc != nil {
+			return cc.roundTrip(req)
+		}
+		if pc := t.getIdle(key); pc != nil {
+			return pc.roundTrip(req)
+		}
+		wait := t.reserveConn(key)
+		if wait == nil {
+			break
+		}
+		select {
+		case <-wait:
+		case <-req.Context().Done():
+			closeRequestBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+	conn, state, forward, err := t.dial(req.Context(), req.URL, proxy)
+	if err != nil {
+		t.releaseConn(key)
+		closeRequestBody(req)
+		return nil, err
+	}
+	if t.maxConnsPerHost > 0 {
+		conn = &countedConn{Conn: conn, t: t, key: key}
+	}
+	if state != nil && state.NegotiatedProtocol == "h2" {
+		cc, err := t.newH2Conn(key, conn, state)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		return cc.roundTrip(req)
+	}
+	pc := &h1Conn{t: t, key: key, conn: conn, tls: state, br: bufio.NewReader(conn), bw: bufio.NewWriter(conn)}
+	if forward {
+		pc.proxyAuth = proxyAuthorization(proxy)
+		pc.forward = true
+	}
+	return pc.roundTrip(req)
+}
+
+//关闭所有空闲连接,正在使用中的连接不受影响
+func (t *orderedTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = make(map[string][]*h1Conn)
+	t.idleN = 0
+	var h2 []*h2Conn
+	for _, conns := range t.h2 {
+		h2 = append(h2, conns...)
+	}
+	t.mu.Unlock()
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+	for _, cc := range h2 {
+		cc.closeIfIdle()
+	}
+}
+
+/*
+建立到目标主机的连接,HTTPS时完成TLS握手
+state为TLS握手的结果,其中的NegotiatedProtocol为ALPN协商的结果,"h2"表示使用HTTP/2;HTTP时为nil
+forward为true表示通过HTTP代理访问http://网址,此时请求行须使用完整的URL
+proxy为nil时直接连接
+*/
+func (t *orderedTransport) dial(ctx context.Context, u *url.URL, proxy *url.URL) (conn net.Conn, state *tls.ConnectionState, forward bool, err error) {
+	addr := canonicalAddr(u)
+	if proxy == nil {
+		conn, err = t.dialTCP(ctx, addr)
+	} else if isSocksProxy(proxy) {
+		conn, err = t.dialTCP(ctx, canonicalAddr(proxy))
+		if err == nil {
+			err = t.socksConnect(ctx, conn, proxy, addr)
+		}
+	} else {
+		conn, err = t.dialTCP(ctx, canonicalAddr(proxy))
+		if err == nil && proxy.Scheme == "https" {
+			var tlsConn *tls.Conn
+			if tlsConn, err = t.stdHandshake(ctx, conn, proxy.Hostname(), []string{"http/1.1"}); err == nil {
+				conn = tlsConn
+			}
+		}
+		if err == nil {
+			if u.Scheme == "http" {
+				return conn, nil, true, nil
+			}
+			err = proxyConnect(ctx, conn, addr, proxyAuthorization(proxy), t.proxyHeader)
+		}
+	}
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, nil, false, err
+	}
+	if u.Scheme == "http" {
+		return conn, nil, false, nil
+	}
+	tlsConn, state, err := t.handshake(ctx, conn, u.Hostname())
+	if err != nil {
+		conn.Close()
+		return nil, nil, false, err
+	}
+	return tlsConn, state, false, nil
+}
+
+//通过SOCKS代理连接addr,超时时间与连接超时时间相同
+func (t *orderedTransport) socksConnect(ctx context.Context, conn net.Conn, proxy *url.URL, addr string) error {
+	if t.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.dialTimeout)
+		defer cancel()
+	}
+	return socksConnect(ctx, conn, proxy, addr)
+}
+
+func (t *orderedTransport) dialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	return t.dialer.DialContext(ctx, "tcp", addr)
+}
+
+//与目标主机的TLS握手,设置了指纹时按指纹握手,否则使用Go标准库
+func (t *orderedTransport) handshake(ctx context.Context, conn net.Conn, serverName string) (net.Conn, *tls.ConnectionState, error) {
+	if t.fp == nil {
+		nextProtos := t.tlsConfig.NextProtos
+		if len(nextProtos) == 0 {
+			nextProtos = []string{"h2", "http/1.1"}
+		}
+		tlsConn, err := t.stdHandshake(ctx, conn, serverName, nextProtos)
+		if err != nil {
+			return nil, nil, err
+		}
+		state := tlsConn.ConnectionState()
+		return tlsConn, &state, nil
+	}
+	if t.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.dialTimeout)
+		defer cancel()
+	}
+	return t.fp.handshake(ctx, conn, t.clientTLSConfig(serverName))
+}
+
+//用Go标准库完成TLS握手,超时时间与连接超时时间相同
+func (t *orderedTransport) stdHandshake(ctx context.Context, conn net.Conn, serverName string, nextProtos []string) (*tls.Conn, error) {
+	cfg := t.clientTLSConfig(serverName)
+	cfg.NextProtos = nextProtos
+	tlsConn := tls.Client(conn, cfg)
+	if t.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.dialTimeout)
+		defer cancel()
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+//本次握手使用的TLS设置,tlsConfig中没有指定SNI时使用serverName
+func (t *orderedTransport) clientTLSConfig(serverName string) *tls.Config {
+	cfg := t.tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = serverName
+	}
+	return cfg
+}
+
+//通过HTTP代理的CONNECT方法建立隧道,header为额外发送的Header,与标准库一样auth优先于其中的Proxy-Authorization
+func proxyConnect(ctx context.Context, conn net.Conn, addr, auth string, header http.Header) error {
+	for k, vv := range header {
+		if err := validateHeaderField(k, vv...); err != nil {
+			return err
+		}
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if auth != "" {
+		req += "Proxy-Authorization: " + auth + "\r\n"
+	}
+	for k, vv := range header {
+		if auth != "" && http.CanonicalHeaderKey(k) == "Proxy-Authorization" {
+			continue
+		}
+		for _, v := range vv {
+			req += k + ": " + v + "\r\n"
+		}
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	//代理不应在200之后立即发送数据
+	if br.Buffered() > 0 {
+		return errors.New("proxy CONNECT: unexpected data after response")
+	}
+	return nil
+}
+
+//代理URL中带有用户名密码时的Proxy-Authorization
+func proxyAuthorization(u *url.URL) string {
+	if u == nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
+}
+
+//host:port形式的地址,没有端口号时按scheme补上默认端口
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch {
+		case u.Scheme == "https":
+			port = "443"
+		case isSocksProxy(u):
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+/*
+检查将要写出的Method,Host及Request Headers,HTTP/1.1及HTTP/2共用
+Header名称按HeaderOrder中的写法检查,与实际写出的一致
+*/
+func validateRequest(req *http.Request) error {
+	if req.Method == "" || strings.IndexFunc(req.Method, func(r rune) bool { return !httpguts.IsTokenRune(r) }) != -1 {
+		return fmt.Errorf("invalid method %q", req.Method)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if !httpguts.ValidHostHeader(host) {
+		return fmt.Errorf("invalid Host header %q", host)
+	}
+	for _, f := range headerOrderFromContext(req.Context()).fields(req.Header, nil, false) {
+		if err := validateHeaderField(f.name, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Header名称必须是token,值中不能有换行等控制字符
+func validateHeaderField(name string, values ...string) error {
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("invalid header field name %q", name)
+	}
+	for _, v := range values {
+		if !httpguts.ValidHeaderFieldValue(v) {
+			return fmt.Errorf("invalid header field value for %q", name)
+		}
+	}
+	return nil
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+//ctx取消时关闭conn以中止正在进行的读写,返回的stop用于结束监视,stop返回false表示conn已因ctx取消而被关闭
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return true }
+	}
+	var state int32 //0:监视中 1:已关闭 2:已停止
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if atomic.CompareAndSwapInt32(&state, 0, 1) {
+				conn.Close()
+			}
+		case <-done:
+		}
+	}()
+	return func() bool {
+		if atomic.CompareAndSwapInt32(&state, 0, 2) {
+			close(done)
+			return true
+		}
+		return atomic.LoadInt32(&state) == 2
+	}
+}
+
+//取出一个未超时的空闲连接,已超时的连接直接关闭
+//关闭连接时会归还连接数名额并加锁,所以都在解锁后关闭
+func (t *orderedTransport) getIdle(key string) *h1Conn {
+	var expired []*h1Conn
+	defer func() {
+		for _, pc := range expired {
+			pc.conn.Close()
+		}
+	}()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.idle[key]
+	for len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		t.idleN--
+		if t.idleConnTimeout <= 0 || time.Since(pc.idleAt) < t.idleConnTimeout {
+			t.idle[key] = conns
+			pc.reused = true
+			return pc
+		}
+		expired = append(expired, pc)
+	}
+	delete(t.idle, key)
+	return nil
+}
+
+//放回空闲连接,超过数量上限时直接关闭
+func (t *orderedTransport) putIdle(pc *h1Conn) {
+	perHost := t.maxIdleConnsPerHost
+	if perHost == 0 {
+		perHost = http.DefaultMaxIdleConnsPerHost
+	}
+	t.mu.Lock()
+	if perHost < 0 || len(t.idle[pc.key]) >= perHost || (t.maxIdleConns > 0 && t.idleN >= t.maxIdleConns) {
+		t.mu.Unlock()
+		pc.conn.Close()
+		return
+	}
+	pc.idleAt = time.Now()
+	t.idle[pc.key] = append(t.idle[pc.key], pc)
+	t.idleN++
+	t.notifyLocked(pc.key)
+	t.mu.Unlock()
+}
+
+/*
+占用一个新建连接的名额,没有设置maxConnsPerHost或未达到上限时返回nil
+已达到上限时返回一个channel,有连接关闭,放回空闲或HTTP/2连接可以复用时关闭,之后应重新查找可用的连接
+*/
+func (t *orderedTransport) reserveConn(key string) <-chan struct{} {
+	if t.maxConnsPerHost <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns[key] < t.maxConnsPerHost {
+		t.conns[key]++
+		return nil
+	}
+	wait := t.connWait[key]
+	if wait == nil {
+		wait = make(chan struct{})
+		t.connWait[key] = wait
+	}
+	return wait
+}
+
+//归还一个连接数名额
+func (t *orderedTransport) releaseConn(key string) {
+	if t.maxConnsPerHost <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns[key]--; t.conns[key] <= 0 {
+		delete(t.conns, key)
+	}
+	t.notifyLocked(key)
+}
+
+//唤醒等待key的连接的请求,调用时须持有t.mu
+func (t *orderedTransport) notifyLocked(key string) {
+	if wait := t.connWait[key]; wait != nil {
+		close(wait)
+		delete(t.connWait, key)
+	}
+}
+
+//计入连接数上限的连接,关闭时归还名额
+type countedConn struct {
+	net.Conn
+	t    *orderedTransport
+	key  string
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.t.releaseConn(c.key)
+	})
+	return err
+}
+
+//一个HTTP/1.1连接,同一时间只处理一个请求
+type h1Conn struct {
+	t         *orderedTransport
+	key       string
+	conn      net.Conn
+	tls       *tls.ConnectionState //HTTP时为nil
+	br        *bufio.Reader
+	bw        *bufio.Writer
+	forward   bool   //通过HTTP代理访问http://网址
+	proxyAuth string //forward为true时发给代理的Proxy-Authorization
+	reused    bool   //是否为复用的空闲连接
+	idleAt    time.Time
+}
+
+func (pc *h1Conn) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	stop := closeOnDone(ctx, pc.conn)
+	fail := func(err error) (*http.Response, error) {
+		stop()
+		pc.conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if err := pc.writeRequest(req); err != nil {
+		if pc.reused && ctx.Err() == nil {
+			return fail(errStaleConn)
+		}
+		return fail(err)
+	}
+	//复用的连接在收到任何响应之前就被关闭,说明服务器已经关闭了这个空闲连接
+	if _, err := pc.br.Peek(1); err != nil {
+		if pc.reused && ctx.Err() == nil {
+			return fail(errStaleConn)
+		}
+		return fail(err)
+	}
+	var resp *http.Response
+	for {
+		var err error
+		resp, err = http.ReadResponse(pc.br, req)
+		if err != nil {
+			return fail(err)
+		}
+		//跳过100 Continue等1xx响应
+		if resp.StatusCode < 100 || resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+	}
+	resp.TLS = pc.tls
+	keepAlive := !resp.Close && !req.Close && !strings.EqualFold(req.Header.Get("Connection"), "close")
+	resp.Body = &h1Body{pc: pc, body: resp.Body, stop: stop, keepAlive: keepAlive}
+	return resp, nil
+}
+
+//按headerOrder写出请求行,Request Headers及body
+func (pc *h1Conn) writeRequest(req *http.Request) error {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	requestURI := req.URL.RequestURI()
+	if pc.forward {
+		requestURI = req.URL.String()
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	extra := []headerField{{"Host", host}}
+	length := outgoingLength(req)
+	if f, ok := contentLengthField(req); ok {
+		extra = append(extra, f)
+	} else if length < 0 {
+		extra = append(extra, headerField{"Transfer-Encoding", "chunked"})
+	}
+	if pc.forward && pc.proxyAuth != "" {
+		extra = append(extra, headerField{"Proxy-Authorization", pc.proxyAuth})
+	}
+	if _, err := fmt.Fprintf(pc.bw, "%s %s HTTP/1.1\r\n", req.Method, requestURI); err != nil {
+		return err
+	}
+	for _, f := range headerOrderFromContext(req.Context()).fields(req.Header, extra, false) {
+		if _, err := fmt.Fprintf(pc.bw, "%s: %s\r\n", f.name, f.value); err != nil {
+			return err
+		}
+	}
+	if _, err := pc.bw.WriteString("\r\n"); err != nil {
+		return err
+	}
+	switch {
+	case length < 0:
+		cw := httputil.NewChunkedWriter(pc.bw)
+		if _, err := io.Copy(cw, req.Body); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		if _, err := pc.bw.WriteString("\r\n"); err != nil {
+			return err
+		}
+	case length > 0:
+		n, err := io.Copy(pc.bw, io.LimitReader(req.Body, length))
+		if err != nil {
+			return err
+		}
+		if n != length {
+			return fmt.Errorf("http: ContentLength=%d with Body length %d", length, n)
+		}
+	}
+	return pc.bw.Flush()
+}
+
+//HTTP/1.1的响应内容,读完后连接放回空闲连接,未读完就关闭时连接也一起关闭
+type h1Body struct {
+	pc        *h1Conn
+	body      io.ReadCloser
+	stop      func() bool
+	keepAlive bool
+	once      sync.Once
+}
+
+func (b *h1Body) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err == io.EOF {
+		b.finish(true)
+	}
+	return n, err
+}
+
+func (b *h1Body) Close() error {
+	b.finish(false)
+	return nil
+}
+
+func (b *h1Body) finish(eof bool) {
+	b.once.Do(func() {
+		//stop返回false表示连接已因ctx取消而被关闭
+		if b.stop() && eof && b.keepAlive {
+			b.pc.t.putIdle(b.pc)
+			return
+		}
+		b.pc.conn.Close()
+	})
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//只读取请求头并原样记录每一行的HTTP/1.1服务器,用于检查线路上的顺序及大小写
+type h1CaptureServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	lines [][]string //每个请求的请求行及全部Header行
+}
+
+func newH1CaptureServer(t *testing.T) *h1CaptureServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &h1CaptureServer{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *h1CaptureServer) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		var lines []string
+		length := 0
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" {
+				break
+			}
+			if i := strings.IndexByte(line, ':'); i > 0 && strings.EqualFold(line[:i], "Content-Length") {
+				length, _ = strconv.Atoi(strings.TrimSpace(line[i+1:]))
+			}
+			lines = append(lines, line)
+		}
+		if _, err := io.CopyN(io.Discard, br, int64(length)); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.lines = append(s.lines, lines)
+		s.mu.Unlock()
+		io.WriteString(c, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\n\r\nok")
+	}
+}
+
+func (s *h1CaptureServer) URL() string {
+	return "http://" + s.ln.Addr().String()
+}
+
+//最近一个请求的Header名称,按线路上的顺序及写法
+func (s *h1CaptureServer) lastNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.lines) == 0 {
+		return nil
+	}
+	var names []string
+	for _, line := range s.lines[len(s.lines)-1][1:] {
+		names = append(names, line[:strings.IndexByte(line, ':')])
+	}
+	return names
+}
+
+func TestOrderedTransportH1HeaderOrder(t *testing.T) {
+	srv := newH1CaptureServer(t)
+	ga := New(
+		WithHeaders(map[string]string{"user-agent": "gather-test", "X-Second": "2", "x-first": "1", "accept": "*/*"}),
+		WithHeaderOrder("x-first", "Host", "user-agent", "X-Second", "accept"),
+		WithOrderedHeaders(true),
+	)
+	defer ga.Close()
+	html, _, err := ga.Get(srv.URL()+"/a", "")
+	if err != nil || html != "ok" {
+		t.Fatal(html, err)
+	}
+	want := []string{"x-first", "Host", "user-agent", "X-Second", "accept"}
+	got := srv.lastNames()
+	if len(got) < len(want) || strings.Join(got[:len(want)], ",") != strings.Join(want, ",") {
+		t.Fatalf("Header顺序有误, 期望以%v开头, 实际%v", want, got)
+	}
+	//不在HeaderOrder中的Header按字母顺序排在最后
+	rest := got[len(want):]
+	for i := 1; i < len(rest); i++ {
+		if http.CanonicalHeaderKey(rest[i-1]) > http.CanonicalHeaderKey(rest[i]) {
+			t.Fatalf("其余Header未按字母顺序: %v", rest)
+		}
+	}
+
+	//运行过程中修改HeaderOrder后立即生效,body为空的POST带上Content-Length: 0
+	ga.HeaderOrder = []string{"content-length", "accept", "Host"}
+	if _, _, err := ga.Post(srv.URL()+"/b", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	got = srv.lastNames()
+	if len(got) < 3 || got[0] != "content-length" || got[1] != "accept" || got[2] != "Host" {
+		t.Fatalf("修改后的Header顺序有误: %v", got)
+	}
+}
+
+func TestOrderedTransportH1DefaultHostFirst(t *testing.T) {
+	srv := newH1CaptureServer(t)
+	ga := New(WithHeaders(map[string]string{"User-Agent": "gather-test", "Accept": "*/*"}), WithOrderedHeaders(true))
+	defer ga.Close()
+	ga.HeaderOrder = nil
+	if _, _, err := ga.Get(srv.URL(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.lastNames(); len(got) == 0 || got[0] != "Host" {
+		t.Fatalf("没有指定顺序时Host应排在最前面: %v", got)
+	}
+}
+
+//按Profile发送时,线路上的顺序与Profile.HeaderOrder一致
+func TestOrderedTransportH1ProfileOrder(t *testing.T) {
+	for _, name := range []string{"chrome", "firefox", "safari"} {
+		srv := newH1CaptureServer(t)
+		ga := New(WithProfile(name), WithOrderedHeaders(true))
+		if _, _, err := ga.Get(srv.URL(), ""); err != nil {
+			t.Fatal(name, err)
+		}
+		got := srv.lastNames()
+		sent := make(map[string]bool, len(got))
+		for _, n := range got {
+			sent[n] = true
+		}
+		var want []string
+		for _, n := range ga.HeaderOrder {
+			if sent[n] {
+				want = append(want, n)
+			}
+		}
+		if len(want) == 0 || strings.Join(got[:len(want)], ",") != strings.Join(want, ",") {
+			t.Fatalf("%s: 期望以%v开头, 实际%v", name, want, got)
+		}
+		ga.Close()
+	}
+}
+
+//服务器关闭空闲连接后,下一个请求换新连接重新发送
+func TestOrderedTransportH1StaleConn(t *testing.T) {
+	var mu sync.Mutex
+	var conns []net.Conn
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	ga := New(WithOrderedHeaders(true))
+	defer ga.Close()
+	for i := 0; i < 3; i++ {
+		html, _, err := ga.Get(srv.URL, "")
+		if err != nil || html != "ok" {
+			t.Fatal(i, html, err)
+		}
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+//Header名称,值或Method中带有换行时返回错误,不向服务器写出任何内容
+func TestOrderedTransportRejectsInvalidHeaders(t *testing.T) {
+	srv := newH1CaptureServer(t)
+	cases := []struct {
+		name string
+		ga   *GatherStruct
+	}{
+		{"header name", New(WithHeaders(map[string]string{"X-A\r\nX-Injected": "1"}), WithOrderedHeaders(true))},
+		{"header value", New(WithHeaders(map[string]string{"X-A": "1\r\nX-Injected: 1"}), WithOrderedHeaders(true))},
+		{"connect header", New(WithProxy(srv.URL()), WithProxyConnectHeaders(map[string]string{"X-A\r\nX-Injected": "1"}), WithOrderedHeaders(true))},
+	}
+	for _, c := range cases {
+		target := srv.URL()
+		if c.name == "connect header" {
+			target = "https://www.example.com/"
+		}
+		if _, _, err := c.ga.Get(target, ""); err == nil || !strings.Contains(err.Error(), "invalid header field") {
+			t.Fatalf("%s: 期望返回invalid header field错误, 实际%v", c.name, err)
+		}
+		c.ga.Close()
+	}
+	ga := New(WithOrderedHeaders(true))
+	defer ga.Close()
+	req, _ := http.NewRequest("GET", srv.URL(), nil)
+	req.Method = "GET / HTTP/1.1\r\nX-Injected: 1\r\n\r\nGET"
+	if _, err := ga.Client.Transport.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "invalid method") {
+		t.Fatalf("期望返回invalid method错误, 实际%v", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.lines) != 0 {
+		t.Fatalf("服务器不应收到任何请求: %q", srv.lines)
+	}
+}
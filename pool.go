@@ -1,7 +1,9 @@
 package gather
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 )
@@ -15,6 +17,7 @@ type Pool struct {
 var errNoFreeClinetFind = fmt.Errorf("time out,no free client find")
 
 //池化技术 同时申明若干个，以备使用，避免频繁的申明回收,最多100个
+//GatherStruct本身已经可以并发使用,只有需要多套互相独立的cookies时才需要Pool
 func NewGatherUtilPool(headers map[string]string, proxyURL string, timeOut int, isCookieLogOpen bool, num int) *Pool {
 	if num <= 0 {
 		num = 1
@@ -22,20 +25,54 @@ func NewGatherUtilPool(headers map[string]string, proxyURL string, timeOut int,
 	if num > 100 {
 		num = 100
 	}
-	//重设一下maxIdleConns以适应不同的需求
-	if num >= 1 && num <= 100 {
-		maxIdleConns = num
+	//按采集器的数量设置这个Pool自己的空闲连接数,不影响其它Pool及GatherStruct
+	opts := gatherUtilOptions(headers, proxyURL, timeOut, isCookieLogOpen)
+	opts = append(opts, WithMaxIdleConns(num), WithMaxIdleConnsPerHost(num))
+	return NewPool(num, opts...)
+}
+
+/*
+以可选参数的方式实例化缓存池,num个采集器的参数相同,但cookies互相独立
+同一个Pool中的采集器共用一个传输层(连接池),与其它Pool及GatherStruct互不影响
+设置了WithTransport时全部使用该传输层
+
+例:
+gp := gather.NewPool(10, gather.WithProfile("chrome"), gather.WithMaxConnsPerHost(10))
+defer gp.Close()
+*/
+func NewPool(num int, opts ...Option) *Pool {
+	if num <= 0 {
+		num = 1
 	}
 	var gp Pool
-
 	for i := 0; i < num; i++ {
-		ga := NewGatherUtil(headers, proxyURL, timeOut, isCookieLogOpen)
+		var ga *GatherStruct
+		if i == 0 {
+			ga = New(opts...)
+		} else {
+			ga = New(append(opts[:len(opts):len(opts)], WithTransport(gp.pool[0].Client.Transport))...)
+		}
 		gp.pool = append(gp.pool, ga)
 		gp.unUsed.Store(i, true)
 	}
 	return &gp
 }
 
+//关闭缓存池中全部采集器的空闲连接,正在进行的请求不受影响
+func (p *Pool) CloseIdleConnections() {
+	for _, ga := range p.pool {
+		ga.CloseIdleConnections()
+	}
+}
+
+//关闭缓存池中的全部采集器,之后的请求都返回ErrGatherClosed
+func (p *Pool) Close() error {
+	for _, ga := range p.pool {
+		ga.Close()
+	}
+	return nil
+}
+
 //从缓存池中 随便获取一个，然后再利用
 func (p *Pool) Get(URL, refererURL string) (html, redirectURL string, err error) {
 	pool_index := p.getPoolIndex()
@@ -76,26 +113,119 @@ func (p *Pool) PostUtil(URL, refererURL, cookies string, postMap map[string]stri
 	return p.pool[pool_index].PostUtil(URL, refererURL, cookies, postMap)
 }
 
-//设置超时30秒超时 ，如果没有找到就返回-1表示失败
+//从缓存池中 随便获取一个，然后再利用,返回完整的Response
+func (p *Pool) GetResponse(URL, refererURL, cookies string) (*Response, error) {
+	pool_index := p.getPoolIndex()
+	if pool_index == -1 {
+		return nil, errNoFreeClinetFind
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].GetResponse(URL, refererURL, cookies)
+}
+
+//从缓存池中 随便获取一个，然后再利用,返回完整的Response
+func (p *Pool) PostResponse(URL, refererURL, cookies string, postMap map[string]string) (*Response, error) {
+	pool_index := p.getPoolIndex()
+	if pool_index == -1 {
+		return nil, errNoFreeClinetFind
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].PostResponse(URL, refererURL, cookies, postMap)
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) GetCtx(ctx context.Context, URL, refererURL string) (html, redirectURL string, err error) {
+	return p.GetUtilCtx(ctx, URL, refererURL, "")
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) GetUtilCtx(ctx context.Context, URL, refererURL, cookies string) (html, redirectURL string, err error) {
+	return htmlResult(p.GetResponseCtx(ctx, URL, refererURL, cookies))
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) GetResponseCtx(ctx context.Context, URL, refererURL, cookies string) (*Response, error) {
+	pool_index, err := p.getPoolIndexCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].GetResponseCtx(ctx, URL, refererURL, cookies)
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) PostCtx(ctx context.Context, URL, refererURL string, postMap map[string]string) (html, redirectURL string, err error) {
+	return p.PostUtilCtx(ctx, URL, refererURL, "", postMap)
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) PostUtilCtx(ctx context.Context, URL, refererURL, cookies string, postMap map[string]string) (html, redirectURL string, err error) {
+	return htmlResult(p.PostResponseCtx(ctx, URL, refererURL, cookies, postMap))
+}
+
+//从缓存池中 随便获取一个，然后再利用,ctx取消或超时后立即返回,包括等待空闲采集器的过程
+func (p *Pool) PostResponseCtx(ctx context.Context, URL, refererURL, cookies string, postMap map[string]string) (*Response, error) {
+	pool_index, err := p.getPoolIndexCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].PostResponseCtx(ctx, URL, refererURL, cookies, postMap)
+}
+
+//从缓存池中 随便获取一个，然后再利用,opts只对本次请求生效
+func (p *Pool) Request(method, URL string, body io.Reader, opts *RequestOptions) (*Response, error) {
+	return p.RequestCtx(context.Background(), method, URL, body, opts)
+}
+
+//从缓存池中 随便获取一个，然后再利用,opts只对本次请求生效,ctx取消或超时后立即返回
+func (p *Pool) RequestCtx(ctx context.Context, method, URL string, body io.Reader, opts *RequestOptions) (*Response, error) {
+	pool_index, err := p.getPoolIndexCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.unUsed.Store(pool_index, true)
+	return p.pool[pool_index].RequestCtx(ctx, method, URL, body, opts)
+}
+
+//不可取消的getPoolIndexCtx,如果没有找到就返回-1表示失败
 func (p *Pool) getPoolIndex() int {
+	pool_index, _ := p.getPoolIndexCtx(context.Background())
+	return pool_index
+}
+
+//设置超时60秒超时,ctx取消时提前返回ctx.Err(),如果没有找到就返回-1与errNoFreeClinetFind
+//只在查找空闲下标时加锁,等待期间不持有锁,以免已取消的调用方被其它等待者阻塞
+func (p *Pool) getPoolIndexCtx(ctx context.Context) (int, error) {
+	for num := 0; num < 600; num++ {
+		if pool_index := p.takeFreeIndex(); pool_index != -1 {
+			return pool_index, nil
+		}
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+	return -1, errNoFreeClinetFind
+}
+
+//取出一个空闲下标并标记为已使用,没有空闲的就返回-1
+func (p *Pool) takeFreeIndex() int {
 	p.locker.Lock()
 	defer p.locker.Unlock()
 	pool_index := -1
-	for num := 0; num < 600; num++ {
-		p.unUsed.Range(func(k, v interface{}) bool {
-			pool_index = k.(int)
-			if pool_index == -1 {
-				return true
-			} else {
-				//false表示不再继续遍历
-				return false
-			}
-		})
-		if pool_index != -1 {
-			p.unUsed.Delete(pool_index)
-			break
+	p.unUsed.Range(func(k, v interface{}) bool {
+		pool_index = k.(int)
+		if pool_index == -1 {
+			return true
+		} else {
+			//false表示不再继续遍历
+			return false
 		}
-		time.Sleep(time.Millisecond * 100)
+	})
+	if pool_index != -1 {
+		p.unUsed.Delete(pool_index)
 	}
 	return pool_index
 }
@@ -8,7 +8,7 @@ package gather
 
 import (
 	"bytes"
-	"net/http"
+	"context"
 	"net/url"
 	"strings"
 )
@@ -46,8 +46,35 @@ postMap["password"] = "abcdef"
 html, redirectURL, err := ga.PostUtil("https://weibo.com/xxxxx", "",cookies, postMap)
 */
 func (g *GatherStruct) PostUtil(URL, refererURL, cookies string, postMap map[string]string) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
+	return htmlResult(g.PostResponse(URL, refererURL, cookies, postMap))
+}
+
+/*
+post方式获取数据,返回包含状态码,响应头等信息的完整Response
+参数含义与PostUtil相同,cookies留空则自动继承先前的cookies
+
+例:
+ga := NewGather("chrome", false)
+postMap := make(map[string]string)
+postMap["user"] = "ydg"
+resp, err := ga.PostResponse("https://weibo.com/xxxxx", "", "", postMap)
+*/
+func (g *GatherStruct) PostResponse(URL, refererURL, cookies string, postMap map[string]string) (*Response, error) {
+	return g.PostResponseCtx(context.Background(), URL, refererURL, cookies, postMap)
+}
+
+//post方式获取数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取,其余参数含义与Post相同
+func (g *GatherStruct) PostCtx(ctx context.Context, URL, refererURL string, postMap map[string]string) (html, redirectURL string, err error) {
+	return g.PostUtilCtx(ctx, URL, refererURL, "", postMap)
+}
+
+//post方式获取数据,手动增加cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostUtil相同
+func (g *GatherStruct) PostUtilCtx(ctx context.Context, URL, refererURL, cookies string, postMap map[string]string) (html, redirectURL string, err error) {
+	return htmlResult(g.PostResponseCtx(ctx, URL, refererURL, cookies, postMap))
+}
+
+//post方式获取数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与PostResponse相同
+func (g *GatherStruct) PostResponseCtx(ctx context.Context, URL, refererURL, cookies string, postMap map[string]string) (*Response, error) {
 	postValues := url.Values{}
 	for k, v := range postMap {
 		postValues.Set(k, v)
@@ -55,26 +82,36 @@ func (g *GatherStruct) PostUtil(URL, refererURL, cookies string, postMap map[str
 	postDataStr := postValues.Encode()
 	postDataBytes := []byte(postDataStr)
 	postBytesReader := bytes.NewReader(postDataBytes)
-	if _, eixst := g.safeHeaders.Load("Content-Type"); !eixst {
-		g.safeHeaders.Store("Content-Type", "application/x-www-form-urlencoded; param=value")
-	}
-	req, err := g.newHttpRequest("POST", URL, refererURL, cookies, postBytesReader)
+	req, err := g.newHttpRequest(ctx, "POST", URL, postBytesReader, "application/x-www-form-urlencoded; param=value", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
 
 //POST二进制
 func (g *GatherStruct) PostBytes(URL, refererURL, cookies string, postBytes []byte) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
+	return htmlResult(g.PostBytesResponse(URL, refererURL, cookies, postBytes))
+}
+
+//POST二进制,返回完整的Response
+func (g *GatherStruct) PostBytesResponse(URL, refererURL, cookies string, postBytes []byte) (*Response, error) {
+	return g.PostBytesResponseCtx(context.Background(), URL, refererURL, cookies, postBytes)
+}
+
+//POST二进制,ctx取消或超时后立即中止抓取
+func (g *GatherStruct) PostBytesCtx(ctx context.Context, URL, refererURL, cookies string, postBytes []byte) (html, redirectURL string, err error) {
+	return htmlResult(g.PostBytesResponseCtx(ctx, URL, refererURL, cookies, postBytes))
+}
+
+//POST二进制,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与PostBytesResponse相同
+func (g *GatherStruct) PostBytesResponseCtx(ctx context.Context, URL, refererURL, cookies string, postBytes []byte) (*Response, error) {
 	postBytesReader := bytes.NewReader(postBytes)
-	req, err := g.newHttpRequest("POST", URL, refererURL, cookies, postBytesReader)
+	req, err := g.newHttpRequest(ctx, "POST", URL, postBytesReader, "", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
 
 /*
@@ -107,17 +144,32 @@ postXML := `<?xml version="1.0" encoding="utf-8"?><loin><user>ydg</user><passord
 html, redirectURL, err := ga.PostXML(`https://weibo.com/xxxxx`, "", cookies, postXML)
 */
 func (g *GatherStruct) PostXMLUtil(URL, refererURL, cookies, postXML string) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
-	//不存在，就写一个默认的进去
-	if _, exist := g.safeHeaders.Load("Content-Type"); !exist {
-		g.safeHeaders.Store("Content-Type", "application/xml")
-	}
-	req, err := g.newHttpRequest("POST", URL, refererURL, cookies, strings.NewReader(postXML))
+	return htmlResult(g.PostXMLResponse(URL, refererURL, cookies, postXML))
+}
+
+//以XML的方式post数据,返回完整的Response,参数含义与PostXMLUtil相同
+func (g *GatherStruct) PostXMLResponse(URL, refererURL, cookies, postXML string) (*Response, error) {
+	return g.PostXMLResponseCtx(context.Background(), URL, refererURL, cookies, postXML)
+}
+
+//以XML的方式post数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostXML相同
+func (g *GatherStruct) PostXMLCtx(ctx context.Context, URL, refererURL, postXML string) (html, redirectURL string, err error) {
+	return g.PostXMLUtilCtx(ctx, URL, refererURL, "", postXML)
+}
+
+//以XML的方式post数据,手动增加cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostXMLUtil相同
+func (g *GatherStruct) PostXMLUtilCtx(ctx context.Context, URL, refererURL, cookies, postXML string) (html, redirectURL string, err error) {
+	return htmlResult(g.PostXMLResponseCtx(ctx, URL, refererURL, cookies, postXML))
+}
+
+//以XML的方式post数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与PostXMLResponse相同
+func (g *GatherStruct) PostXMLResponseCtx(ctx context.Context, URL, refererURL, cookies, postXML string) (*Response, error) {
+	//默认Request Headers中没有Content-Type时,本次请求使用application/xml
+	req, err := g.newHttpRequest(ctx, "POST", URL, strings.NewReader(postXML), "application/xml", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
 
 /*
@@ -151,57 +203,29 @@ postJson := `{"user":"ydg","password":"abcdesg"}`
 html, redirectURL, err := ga.PostJsonUtil(`https://weibo.com/xxxxx`, "", cookies, postJson)
 */
 func (g *GatherStruct) PostJsonUtil(URL, refererURL, cookies, postJson string) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
-	if _, exist := g.safeHeaders.Load("Content-Type"); !exist {
-		g.safeHeaders.Store("Content-Type", "application/json")
-	}
-	req, err := g.newHttpRequest("POST", URL, refererURL, cookies, strings.NewReader(postJson))
-	if err != nil {
-		return "", "", err
-	}
-	return g.request(req)
+	return htmlResult(g.PostJsonResponse(URL, refererURL, cookies, postJson))
 }
 
-//multipart/form-data 上传文件的结构体
-type multipartPostFile struct {
-	fileName    string
-	contentType string
-	content     []byte
+//以json的方式post数据,返回完整的Response,参数含义与PostJsonUtil相同
+func (g *GatherStruct) PostJsonResponse(URL, refererURL, cookies, postJson string) (*Response, error) {
+	return g.PostJsonResponseCtx(context.Background(), URL, refererURL, cookies, postJson)
 }
 
-//multipart/form-data方式POST数据,手动增加cookies
-func (g *GatherStruct) PostMultipartformData(URL, refererURL, cookies, boundary string, postValueMap map[string]string, postFileMap map[string]multipartPostFile) (html, redirectURL string, err error) {
-	return g.PostMultipartformDataUtil(URL, refererURL, "", boundary, postValueMap, postFileMap)
+//以json的方式post数据,自动继承先前的cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostJson相同
+func (g *GatherStruct) PostJsonCtx(ctx context.Context, URL, refererURL, postJson string) (html, redirectURL string, err error) {
+	return g.PostJsonUtilCtx(ctx, URL, refererURL, "", postJson)
 }
 
-//multipart/form-data方式POST数据,自动继承先前的cookies
-//boundary指post“分割边界”,这个“边界数据”不能在内容其他地方出现,一般来说使用一段从概率上说“几乎不可能”的数据即可
-//postValueMap指post的普通文本,只包含name和value
-//postFileMap指上传的文件,比如图片,需在调用此函数前自行转换成[]byte,当然POST协议也可使用base64编码后,不过在此忽略此用法,base64也请转换成[]byte
-//multipart/form-data数据格式参见标准库中： mime\multipart\testdata\nested-mime,注意此处file文件是用的base64编码后的
-func (g *GatherStruct) PostMultipartformDataUtil(URL, refererURL, cookies, boundary string, postValueMap map[string]string, postFileMap map[string]multipartPostFile) (html, redirectURL string, err error) {
-	g.locker.Lock()
-	defer g.locker.Unlock()
-	if boundary == "" {
-		boundary = `--WebKitFormBoundaryTP3TumA8yjBZCv2R`
-	}
-	postData := ``
-	for name, value := range postValueMap {
-		postData = postData + boundary + "\r\n" +
-			`Content-Disposition: form-data; name="` + name + `"` + "\r\n\r\n" + value
-	}
-	for name, onePostFile := range postFileMap {
-		postData = postData + boundary + "\r\n" +
-			`Content-Disposition: form-data; name="` + name + `"; filename="` + onePostFile.fileName + `"` + "\r\n" +
-			`Content-Type: ` + onePostFile.contentType + "\r\n\r\n" +
-			string(onePostFile.content)
-	}
-	postData = postData + "\r\n" + boundary + `--`
-	g.safeHeaders.Store("Content-Type", "multipart/form-data; boundary="+boundary)
-	req, err := http.NewRequest("POST", URL, strings.NewReader(postData))
+//以json的方式post数据,手动增加cookies,ctx取消或超时后立即中止抓取,其余参数含义与PostJsonUtil相同
+func (g *GatherStruct) PostJsonUtilCtx(ctx context.Context, URL, refererURL, cookies, postJson string) (html, redirectURL string, err error) {
+	return htmlResult(g.PostJsonResponseCtx(ctx, URL, refererURL, cookies, postJson))
+}
+
+//以json的方式post数据,返回完整的Response,ctx取消或超时后立即中止抓取,其余参数含义与PostJsonResponse相同
+func (g *GatherStruct) PostJsonResponseCtx(ctx context.Context, URL, refererURL, cookies, postJson string) (*Response, error) {
+	req, err := g.newHttpRequest(ctx, "POST", URL, strings.NewReader(postJson), "application/json", refererAndCookies(refererURL, cookies))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return g.request(req)
+	return g.request(req, nil)
 }
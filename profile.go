@@ -0,0 +1,295 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+模拟的浏览器或搜索引擎,提供一整套相互一致的默认Request Headers
+如Chrome的User-Agent,sec-ch-ua,Accept等版本号及平台须相互对应,否则很容易被识别出来
+内置的Profile可通过ProfileNames查看,也可以用RegisterProfile注册自己的Profile或替换内置的Profile
+
+例:
+p, _ := gather.GetProfile("chrome")
+p.Name = "chrome-en"
+p.Headers["Accept-Language"] = "en-US,en;q=0.9"
+gather.RegisterProfile(p)
+ga := gather.NewGather("chrome-en", false)
+*/
+type Profile struct {
+	Name    string            //名称,不区分大小写
+	Headers map[string]string //完整的默认Request Headers,包括User-Agent
+	//浏览器发送Request Headers的顺序及写法,如"sec-ch-ua","User-Agent",只有开启WithOrderedHeaders时严格生效
+	HeaderOrder []string
+	//浏览器发送HTTP/2伪头部的顺序,如Chrome为":method",":authority",":scheme",":path"
+	PseudoHeaderOrder []string
+	//TLS ClientHello及HTTP/2 SETTINGS指纹的名称,见FingerprintNames,只有开启WithBrowserFingerprint时生效,为空时使用Go标准库的TLS
+	Fingerprint string
+}
+
+//复制一份,以免调用方修改注册表中的Profile
+func (p *Profile) clone() *Profile {
+	c := *p
+	c.Headers = make(map[string]string, len(p.Headers))
+	for k, v := range p.Headers {
+		c.Headers[k] = v
+	}
+	c.HeaderOrder = append([]string(nil), p.HeaderOrder...)
+	c.PseudoHeaderOrder = append([]string(nil), p.PseudoHeaderOrder...)
+	return &c
+}
+
+//不指定名称时使用的Profile
+const defaultProfileName = "chrome"
+
+var (
+	profilesLocker sync.RWMutex
+	profiles       = make(map[string]*Profile)
+)
+
+//注册一个Profile,名称相同时替换原有的,之后可通过NewGather,WithProfile按名称使用
+func RegisterProfile(p *Profile) {
+	profilesLocker.Lock()
+	defer profilesLocker.Unlock()
+	profiles[strings.ToLower(p.Name)] = p.clone()
+}
+
+//按名称获取一个Profile的副本,修改副本不影响已注册的Profile
+func GetProfile(name string) (*Profile, bool) {
+	profilesLocker.RLock()
+	defer profilesLocker.RUnlock()
+	p, exist := profiles[strings.ToLower(name)]
+	if !exist {
+		return nil, false
+	}
+	return p.clone(), true
+}
+
+//全部已注册的Profile名称,按字母排序
+func ProfileNames() []string {
+	profilesLocker.RLock()
+	defer profilesLocker.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//根据模拟的浏览器或搜索引擎名称得到Profile,名称为空时使用chrome
+//无法识别的名称直接作为User-Agent使用,其余Request Headers及顺序与chrome相同
+func profileFor(agent string) *Profile {
+	if agent == "" {
+		agent = defaultProfileName
+	}
+	if p, exist := GetProfile(agent); exist {
+		return p
+	}
+	p, _ := GetProfile(defaultProfileName)
+	p.Name = agent
+	p.Headers["User-Agent"] = agent
+	//自定义的User-Agent不一定是Chromium内核,不能带上与之矛盾的Client Hints
+	for k := range p.Headers {
+		if strings.HasPrefix(strings.ToLower(k), "sec-ch-ua") {
+			delete(p.Headers, k)
+		}
+	}
+	return p
+}
+
+//带Referer时,按Referer与目标URL的关系修正Sec-Fetch-Site,与浏览器从一个页面点击链接时一致
+//Profile中的Sec-Fetch-Site是直接在地址栏输入网址时的值none
+func fixSecFetchSite(req *http.Request) {
+	if req.Header.Get("Sec-Fetch-Site") == "" {
+		return
+	}
+	referer := req.Header.Get("Referer")
+	if referer == "" {
+		return
+	}
+	site := "cross-site"
+	if ref, err := req.URL.Parse(referer); err == nil {
+		if strings.EqualFold(ref.Scheme, req.URL.Scheme) && strings.EqualFold(ref.Host, req.URL.Host) {
+			site = "same-origin"
+		} else if sameSite(ref.Hostname(), req.URL.Hostname()) {
+			site = "same-site"
+		}
+	}
+	req.Header.Set("Sec-Fetch-Site", site)
+}
+
+//两个主机是否属于同一个站点,即eTLD+1相同
+func sameSite(a, b string) bool {
+	a, errA := canonicalHost(a)
+	b, errB := canonicalHost(b)
+	return errA == nil && errB == nil && jarKey(a) == jarKey(b)
+}
+
+//Chromium内核浏览器直接访问网页时的Accept
+const chromiumAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+
+//Chromium内核浏览器的默认Request Headers
+func chromiumHeaders(userAgent, secChUa, mobile, platform string) map[string]string {
+	return map[string]string{
+		"sec-ch-ua":                 secChUa,
+		"sec-ch-ua-mobile":          mobile,
+		"sec-ch-ua-platform":        platform,
+		"Upgrade-Insecure-Requests": "1",
+		"User-Agent":                userAgent,
+		"Accept":                    chromiumAccept,
+		"Sec-Fetch-Site":            "none",
+		"Sec-Fetch-Mode":            "navigate",
+		"Sec-Fetch-User":            "?1",
+		"Sec-Fetch-Dest":            "document",
+		"Accept-Encoding":           defaultAcceptEncoding,
+		"Accept-Language":           "zh-CN,zh;q=0.9,en;q=0.8",
+		"Priority":                  "u=0, i",
+		"Connection":                "keep-alive",
+	}
+}
+
+//Firefox的默认Request Headers
+func firefoxHeaders(userAgent string) map[string]string {
+	return map[string]string{
+		"User-Agent":                userAgent,
+		"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"Accept-Language":           "zh-CN,zh;q=0.8,zh-TW;q=0.7,zh-HK;q=0.5,en-US;q=0.3,en;q=0.2",
+		"Accept-Encoding":           defaultAcceptEncoding,
+		"Connection":                "keep-alive",
+		"Upgrade-Insecure-Requests": "1",
+		"Sec-Fetch-Dest":            "document",
+		"Sec-Fetch-Mode":            "navigate",
+		"Sec-Fetch-Site":            "none",
+		"Sec-Fetch-User":            "?1",
+		"Priority":                  "u=0, i",
+	}
+}
+
+//Safari及iOS上基于WebKit的浏览器的默认Request Headers,Safari不支持zstd
+func webkitHeaders(userAgent string) map[string]string {
+	return map[string]string{
+		"Sec-Fetch-Dest":  "document",
+		"User-Agent":      userAgent,
+		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"Sec-Fetch-Site":  "none",
+		"Sec-Fetch-Mode":  "navigate",
+		"Accept-Language": "zh-CN,zh-Hans;q=0.9",
+		"Priority":        "u=0, i",
+		"Accept-Encoding": "gzip, deflate, br",
+		"Connection":      "keep-alive",
+	}
+}
+
+//搜索引擎爬虫的默认Request Headers
+func botHeaders(userAgent string) map[string]string {
+	return map[string]string{
+		"User-Agent":      userAgent,
+		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"Accept-Encoding": "gzip, deflate, br",
+		"Accept-Language": "zh-CN,zh;q=0.9",
+		"Connection":      "keep-alive",
+	}
+}
+
+//早期浏览器的默认Request Headers,只为兼容原有的"360","ie","ie9"等名称
+func legacyHeaders(userAgent string) map[string]string {
+	return map[string]string{
+		"User-Agent":                userAgent,
+		"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
+		"Accept-Encoding":           "gzip, deflate",
+		"Accept-Language":           "zh-CN,zh;q=0.8",
+		"Connection":                "keep-alive",
+		"Upgrade-Insecure-Requests": "1",
+	}
+}
+
+//Chromium内核浏览器发送Request Headers的顺序
+var chromiumOrder = []string{
+	"Host", "Connection", "Content-Length", "Cache-Control", "sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform",
+	"Upgrade-Insecure-Requests", "Origin", "Content-Type", "User-Agent", "Accept", "X-Requested-With",
+	"Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest", "Referer",
+	"Accept-Encoding", "Accept-Language", "Cookie", "Priority",
+}
+
+//Firefox发送Request Headers的顺序
+var firefoxOrder = []string{
+	"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding", "Content-Type", "Content-Length",
+	"Origin", "Connection", "Referer", "Cookie", "Upgrade-Insecure-Requests",
+	"Sec-Fetch-Dest", "Sec-Fetch-Mode", "Sec-Fetch-Site", "Sec-Fetch-User", "Priority",
+}
+
+//WebKit内核浏览器发送Request Headers的顺序
+var webkitOrder = []string{
+	"Host", "Content-Type", "Origin", "Sec-Fetch-Dest", "User-Agent", "Accept", "Referer", "Sec-Fetch-Site",
+	"Sec-Fetch-Mode", "Content-Length", "Accept-Language", "Priority", "Accept-Encoding", "Cookie", "Connection",
+}
+
+//搜索引擎爬虫及早期浏览器发送Request Headers的顺序
+var plainOrder = []string{
+	"Host", "Connection", "Upgrade-Insecure-Requests", "User-Agent", "Accept", "Content-Type", "Content-Length",
+	"Referer", "Accept-Encoding", "Accept-Language", "Cookie",
+}
+
+//各浏览器发送HTTP/2伪头部的顺序
+var (
+	chromiumPseudoOrder = []string{":method", ":authority", ":scheme", ":path"}
+	firefoxPseudoOrder  = []string{":method", ":path", ":authority", ":scheme"}
+	webkitPseudoOrder   = []string{":method", ":scheme", ":authority", ":path"}
+)
+
+//内置的Profile,版本号请随浏览器更新,也可在程序中用RegisterProfile替换
+func init() {
+	const chromeUa = `"Chromium";v="140", "Not=A?Brand";v="24", "Google Chrome";v="140"`
+	builtin := []*Profile{
+		{"chrome", chromiumHeaders(
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36",
+			chromeUa, "?0", `"Windows"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		{"chrome-mac", chromiumHeaders(
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36",
+			chromeUa, "?0", `"macOS"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		{"chrome-android", chromiumHeaders(
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Mobile Safari/537.36",
+			chromeUa, "?1", `"Android"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		{"edge", chromiumHeaders(
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36 Edg/140.0.0.0",
+			`"Chromium";v="140", "Not=A?Brand";v="24", "Microsoft Edge";v="140"`, "?0", `"Windows"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		{"edge-android", chromiumHeaders(
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Mobile Safari/537.36 EdgA/140.0.0.0",
+			`"Chromium";v="140", "Not=A?Brand";v="24", "Microsoft Edge";v="140"`, "?1", `"Android"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		//360安全浏览器使用Chromium内核,User-Agent与Chrome相同
+		{"360", chromiumHeaders(
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/132.0.0.0 Safari/537.36",
+			`"Chromium";v="132", "Not A(Brand";v="8"`, "?0", `"Windows"`), chromiumOrder, chromiumPseudoOrder, "chrome"},
+		{"firefox", firefoxHeaders("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:143.0) Gecko/20100101 Firefox/143.0"), firefoxOrder, firefoxPseudoOrder, "firefox"},
+		{"firefox-mac", firefoxHeaders("Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:143.0) Gecko/20100101 Firefox/143.0"), firefoxOrder, firefoxPseudoOrder, "firefox"},
+		{"firefox-android", firefoxHeaders("Mozilla/5.0 (Android 14; Mobile; rv:143.0) Gecko/143.0 Firefox/143.0"), firefoxOrder, firefoxPseudoOrder, "firefox"},
+		{"safari", webkitHeaders("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.6 Safari/605.1.15"), webkitOrder, webkitPseudoOrder, "safari"},
+		{"safari-ios", webkitHeaders("Mozilla/5.0 (iPhone; CPU iPhone OS 18_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.6 Mobile/15E148 Safari/604.1"), webkitOrder, webkitPseudoOrder, "safari"},
+		{"wechat-ios", webkitHeaders("Mozilla/5.0 (iPhone; CPU iPhone OS 18_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 MicroMessenger/8.0.61(0x18003d2f) NetType/WIFI Language/zh_CN"), webkitOrder, webkitPseudoOrder, "safari"},
+		{"baidu", botHeaders("Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)"), plainOrder, nil, ""},
+		{"google", botHeaders("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"), plainOrder, nil, ""},
+		{"bing", botHeaders("Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"), plainOrder, nil, ""},
+		{"sogou", botHeaders("Sogou web spider/4.0(+http://www.sogou.com/docs/help/webmasters.htm#07)"), plainOrder, nil, ""},
+		{"ie", legacyHeaders("Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Win64; x64; Trident/5.0)"), plainOrder, nil, ""},
+		{"ie9", legacyHeaders("Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Win64; x64; Trident/5.0)"), plainOrder, nil, ""},
+	}
+	//微信内置浏览器在安卓上基于Chromium(XWEB),通过X-Requested-With标明所在的应用
+	wechat := chromiumHeaders(
+		"Mozilla/5.0 (Linux; Android 14; V2254A Build/UP1A.231005.007; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/130.0.6723.103 Mobile Safari/537.36 XWEB/1300289 MMWEBSDK/20241103 MMWEBID/6533 MicroMessenger/8.0.55.2780(0x28003737) WeChat/arm64 Weixin NetType/WIFI Language/zh_CN ABI/arm64",
+		`"Chromium";v="130", "Android WebView";v="130", "Not?A_Brand";v="99"`, "?1", `"Android"`)
+	wechat["X-Requested-With"] = "com.tencent.mm"
+	builtin = append(builtin, &Profile{"wechat", wechat, chromiumOrder, chromiumPseudoOrder, "chrome"})
+	for _, p := range builtin {
+		RegisterProfile(p)
+	}
+}
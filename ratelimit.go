@@ -0,0 +1,162 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+//记录的主机数超过此值时,清理长时间没有访问的主机
+const rateLimiterMaxHosts = 1024
+
+/*
+限速器,每个主机一个令牌桶,另有一个所有主机共用的令牌桶,同一主机两次请求之间还可以设置最小间隔及随机抖动
+同一个限速器可以被多个GatherStruct及Pool共用,从而在整个程序范围内控制对目标网站的访问频率
+所有参数为0时表示不限制,参数请在开始抓取之前设置好
+
+例:
+limiter := gather.NewRateLimiter(2, 1) //每个主机每秒最多2个请求
+limiter.MinDelay = time.Second        //同一主机两次请求之间至少间隔1秒
+limiter.Jitter = 500 * time.Millisecond
+ga := gather.New(gather.WithRateLimiter(limiter))
+*/
+type RateLimiter struct {
+	PerHostRate  float64       //每个主机每秒允许的请求数
+	PerHostBurst int           //每个主机允许的突发请求数,小于1时按1处理
+	GlobalRate   float64       //所有主机合计每秒允许的请求数
+	GlobalBurst  int           //所有主机合计允许的突发请求数,小于1时按1处理
+	MinDelay     time.Duration //同一主机两次请求之间的最小间隔
+	Jitter       time.Duration //在MinDelay之外再随机增加0至Jitter的间隔,使访问间隔不那么规律
+
+	mu     sync.Mutex
+	global tokenBucket
+	hosts  map[string]*hostLimit
+}
+
+//每个主机的限速状态
+type hostLimit struct {
+	bucket tokenBucket
+	next   time.Time //下一个请求最早可以开始的时间,由MinDelay及Jitter决定
+}
+
+//令牌桶,令牌数可以为负数,表示已被预约的未来的令牌
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//预约一个令牌,返回需要等待的时间
+func (b *tokenBucket) reserve(now time.Time, rate float64, burst int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+		b.last = now
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+//新建限速器,perHostRate指每个主机每秒允许的请求数,perHostBurst指允许的突发请求数
+func NewRateLimiter(perHostRate float64, perHostBurst int) *RateLimiter {
+	return &RateLimiter{PerHostRate: perHostRate, PerHostBurst: perHostBurst}
+}
+
+/*
+等待直到可以向host发出下一个请求,ctx取消时返回ctx.Err(),已预约的令牌会被归还
+host不区分大小写,可以带端口号
+*/
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	now := time.Now()
+	l.mu.Lock()
+	if l.hosts == nil {
+		l.hosts = make(map[string]*hostLimit)
+	}
+	if len(l.hosts) > rateLimiterMaxHosts {
+		l.prune(now)
+	}
+	h := l.hosts[host]
+	if h == nil {
+		h = &hostLimit{}
+		l.hosts[host] = h
+	}
+	wait := l.global.reserve(now, l.GlobalRate, l.GlobalBurst)
+	if d := h.bucket.reserve(now, l.PerHostRate, l.PerHostBurst); d > wait {
+		wait = d
+	}
+	if d := h.next.Sub(now); d > wait {
+		wait = d
+	}
+	prevNext := h.next
+	if l.MinDelay > 0 || l.Jitter > 0 {
+		delay := l.MinDelay
+		if l.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(l.Jitter) + 1))
+		}
+		h.next = now.Add(wait + delay)
+	}
+	reservedNext := h.next
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		//归还预约的令牌及间隔
+		l.mu.Lock()
+		if l.GlobalRate > 0 {
+			l.global.tokens++
+		}
+		if l.PerHostRate > 0 {
+			h.bucket.tokens++
+		}
+		//之后其它请求的预约以本次预约为基础,只有本次仍是最后一个预约时才能归还间隔
+		if h.next.Equal(reservedNext) {
+			h.next = prevNext
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+//清理长时间没有访问的主机,调用时须已持有l.mu
+func (l *RateLimiter) prune(now time.Time) {
+	for host, h := range l.hosts {
+		if now.Sub(h.bucket.last) > 10*time.Minute && now.After(h.next) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+//给缓存池中的所有采集器设置同一个限速器,从而由整个缓存池共同遵守限速
+func (p *Pool) SetRateLimiter(limiter *RateLimiter) {
+	for _, ga := range p.pool {
+		ga.Limiter = limiter
+	}
+}
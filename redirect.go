@@ -0,0 +1,117 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//跳转次数超过RedirectPolicy.MaxHops时返回的错误,可通过errors.Is判断
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+//不设置MaxHops时允许的最多跳转次数,与http.Client一致
+const defaultMaxRedirects = 10
+
+/*
+跳转策略,按NoFollow,SameHostOnly,MaxHops,Check的顺序检查
+因NoFollow或SameHostOnly停止跳转时,直接返回301,302等响应,此时3xx状态码视为成功,可读取其中的Location及Set-Cookie
+
+例:
+ga := gather.New(gather.WithRedirectPolicy(&gather.RedirectPolicy{NoFollow: true}))
+resp, err := ga.GetResponse("https://www.baidu.com/xxxxx", "", "")
+fmt.Println(resp.StatusCode, resp.Header.Get("Location"))
+*/
+type RedirectPolicy struct {
+	NoFollow     bool //不跟随跳转
+	SameHostOnly bool //只跟随跳转到同一主机的跳转,跳转到其它主机时停止
+	MaxHops      int  //最多跳转次数,超过时返回ErrTooManyRedirects,为0时为10次
+	//自定义检查,与http.Client的CheckRedirect含义相同,可返回http.ErrUseLastResponse停止跳转
+	Check func(req *http.Request, via []*http.Request) error
+}
+
+//一次跳转的记录
+type RedirectHop struct {
+	URL        string      //发生跳转的URL
+	StatusCode int         //跳转的状态码,如301,302
+	Header     http.Header //跳转时的响应头,可从中读取Set-Cookie等信息
+	Location   string      //跳转的目标
+}
+
+//单次抓取过程中的跳转状态,通过请求的context传递给checkRedirect
+type redirectState struct {
+	policy  *RedirectPolicy //本次抓取单独设置的跳转策略,为nil时使用GatherStruct.Redirect
+	stopped bool            //是否因跳转策略停止了跳转
+}
+
+type redirectStateKey struct{}
+
+//在req上附加本次抓取的跳转状态
+func withRedirectState(req *http.Request, policy *RedirectPolicy) (*http.Request, *redirectState) {
+	state := &redirectState{policy: policy}
+	return req.WithContext(context.WithValue(req.Context(), redirectStateKey{}, state)), state
+}
+
+//作为http.Client的CheckRedirect,依次使用本次抓取单独设置的跳转策略及GatherStruct.Redirect
+func (g *GatherStruct) checkRedirect(req *http.Request, via []*http.Request) error {
+	state, _ := req.Context().Value(redirectStateKey{}).(*redirectState)
+	policy := g.Redirect
+	if state != nil && state.policy != nil {
+		policy = state.policy
+	}
+	if policy == nil {
+		policy = &RedirectPolicy{}
+	}
+	err := policy.check(req, via)
+	if err == http.ErrUseLastResponse && state != nil {
+		state.stopped = true
+	}
+	//每一次跳转同样是一次请求,也要遵守限速
+	if limiter := g.Limiter; err == nil && limiter != nil {
+		err = limiter.Wait(req.Context(), req.URL.Host)
+	}
+	return err
+}
+
+func (p *RedirectPolicy) check(req *http.Request, via []*http.Request) error {
+	if p.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	if p.SameHostOnly && len(via) > 0 && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return http.ErrUseLastResponse
+	}
+	maxHops := p.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxRedirects
+	}
+	if len(via) >= maxHops {
+		return fmt.Errorf("stopped after %d redirects: %w", maxHops, ErrTooManyRedirects)
+	}
+	if p.Check != nil {
+		return p.Check(req, via)
+	}
+	return nil
+}
+
+//跳转记录,由最终请求沿Response字段逐级回溯得到
+func redirectHops(finalReq *http.Request) []RedirectHop {
+	var hops []RedirectHop
+	for req := finalReq; req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		resp := req.Response
+		hop := RedirectHop{
+			URL:        resp.Request.URL.String(),
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Location:   req.URL.String(),
+		}
+		hops = append([]RedirectHop{hop}, hops...)
+	}
+	return hops
+}
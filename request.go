@@ -0,0 +1,106 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+//单次请求的参数,只对本次请求生效,不会改变GatherStruct中的默认Request Headers
+//GatherStruct中的Headers仅作为默认值,与这里同名的Header以这里为准
+type RequestOptions struct {
+	Headers     map[string]string //本次请求额外设置的Request Headers
+	Referer     string            //上一次访问的URL。某些防抓取比较严格的网站会对上次访问的页面URL进行验证
+	Cookies     string            //文本形式的cookies,与cookie保存对象中的cookies一起发送
+	ContentType string            //本次请求的Content-Type,留空则使用默认值
+	//本次请求视为成功的状态码,为nil时使用GatherStruct.SuccessStatusCodes
+	SuccessStatusCodes []int
+	//本次请求的跳转策略,为nil时使用GatherStruct.Redirect
+	Redirect *RedirectPolicy
+	//本次请求响应内容的最大长度,为0时使用GatherStruct.MaxBodySize,小于0表示不限制
+	MaxBodySize int64
+	//响应内容超过MaxBodySize时截断而不是返回ErrBodyTooLarge,GatherStruct.TruncateBody为true时总是截断
+	TruncateBody bool
+	//本次请求使用的代理,格式与WithProxy相同,优先于代理池,SetProxy及创建时指定的代理,为空时不改变
+	Proxy string
+	//本次请求不使用任何代理,直接连接,Proxy不为空时以Proxy为准
+	NoProxy bool
+}
+
+//把单次请求的参数设置到req上
+func (o *RequestOptions) apply(req *http.Request) {
+	if o.ContentType != "" {
+		req.Header.Set("Content-Type", o.ContentType)
+	}
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	if o.Referer != "" {
+		req.Header.Set("Referer", o.Referer)
+	}
+	if o.Cookies != "" {
+		req.Header.Set("Cookie", o.Cookies)
+	}
+}
+
+//本次请求指定的代理,没有指定时返回nil
+func (o *RequestOptions) proxy() (*url.URL, error) {
+	if o.Proxy != "" {
+		return parseProxyURL(o.Proxy)
+	}
+	if o.NoProxy {
+		return directProxy, nil
+	}
+	return nil, nil
+}
+
+//修改一个默认的Request Headers,对之后的所有抓取生效,可以在其它goroutine抓取的同时调用
+func (g *GatherStruct) SetHeader(key, value string) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.Headers[key] = value
+	g.safeHeaders.Store(key, value)
+}
+
+//删除一个默认的Request Headers,对之后的所有抓取生效,可以在其它goroutine抓取的同时调用
+func (g *GatherStruct) DelHeader(key string) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	delete(g.Headers, key)
+	g.safeHeaders.Delete(key)
+}
+
+//由原有的refererURL,cookies参数生成单次请求的参数
+func refererAndCookies(refererURL, cookies string) *RequestOptions {
+	return &RequestOptions{Referer: refererURL, Cookies: cookies}
+}
+
+/*
+以任意method发送任意body,opts只对本次请求生效,可以为nil
+返回包含状态码,响应头等信息的完整Response
+
+例:
+ga := NewGather("chrome", false)
+opts := &RequestOptions{Referer: "https://www.baidu.com/", ContentType: "application/json"}
+opts.Headers = map[string]string{"X-Requested-With": "XMLHttpRequest"}
+resp, err := ga.Request("PUT", "https://www.baidu.com/xxxxx", strings.NewReader(`{"id":1}`), opts)
+*/
+func (g *GatherStruct) Request(method, URL string, body io.Reader, opts *RequestOptions) (*Response, error) {
+	return g.RequestCtx(context.Background(), method, URL, body, opts)
+}
+
+//以任意method发送任意body,ctx取消或超时后立即中止抓取,其余与Request相同
+func (g *GatherStruct) RequestCtx(ctx context.Context, method, URL string, body io.Reader, opts *RequestOptions) (*Response, error) {
+	req, err := g.newHttpRequest(ctx, method, URL, body, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	return g.request(req, opts)
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//一次抓取的完整结果,除了html之外,还包含状态码,响应头,cookies,耗时等信息
+type Response struct {
+	StatusCode int            //http状态码
+	Status     string         //http状态行,如"200 OK"
+	Proto      string         //协议版本,如"HTTP/1.1"
+	Header     http.Header    //响应头
+	Body       []byte         //响应内容,已按Content-Encoding自动解压,但保持网页原本的编码
+	Truncated  bool           //响应内容超过MaxBodySize并设置了TruncateBody时为true,此时Body只包含前MaxBodySize字节
+	Charset    string         //自动判断出的网页编码,如"utf-8","gbk","gb18030",不是文本内容或无法判断时为空
+	FinalURL   string         //最终实际访问到内容的URL。因为有时候会碰到301跳转等情况，最终访问的URL并非输入的URL
+	Redirects  []string       //跳转链,按顺序记录最终URL之前经过的每一个URL,没有跳转时为空
+	Hops       []RedirectHop  //每一次跳转的URL,状态码及响应头,与Redirects一一对应
+	SetCookies []*http.Cookie //本次响应中服务器通过Set-Cookie下发的cookies
+	Cookies    []*http.Cookie //本次抓取完成后,cookie保存对象中对应FinalURL的全部cookies
+	Elapsed    time.Duration  //从发出请求到读取完响应内容的总耗时
+	Request    *http.Request  //发起本次抓取的原始请求
+	text       []byte         //转换为UTF-8后的内容,未转换时为nil
+}
+
+//以文本形式返回响应内容,HTMLShouldConvertToUTF8开启时返回转换为UTF-8之后的内容
+func (r *Response) Text() string {
+	if r.text != nil {
+		return string(r.text)
+	}
+	return string(r.Body)
+}
+
+//返回响应头中的Content-Type
+func (r *Response) ContentType() string {
+	return r.Header.Get("Content-Type")
+}
+
+/*
+状态码不被认为是成功时返回的错误,包含完整的响应头及响应内容,可以读取400的JSON错误信息或403的拦截页面
+
+例:
+resp, err := ga.GetResponse("https://www.baidu.com/xxxxx", "", "")
+var statusErr *gather.StatusError
+if errors.As(err, &statusErr) && statusErr.StatusCode == 404 {...}
+*/
+type StatusError struct {
+	StatusCode int         //http状态码
+	Status     string      //http状态行,如"404 Not Found"
+	Header     http.Header //响应头
+	Body       []byte      //响应内容,与Response.Body相同
+	URL        string      //最终实际访问的URL
+}
+
+func (e *StatusError) Error() string {
+	return "http状态码:" + strconv.Itoa(e.StatusCode)
+}
+
+func newStatusError(r *Response) *StatusError {
+	return &StatusError{
+		StatusCode: r.StatusCode,
+		Status:     r.Status,
+		Header:     r.Header,
+		Body:       r.Body,
+		URL:        r.FinalURL,
+	}
+}
+
+//codes为nil时所有2xx都视为成功
+func isSuccessStatus(statusCode int, codes []int) bool {
+	if codes == nil {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, code := range codes {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+//把Response转换成原有的html, redirectURL, err形式的返回值
+func htmlResult(resp *Response, err error) (html, redirectURL string, e error) {
+	if err != nil {
+		return "", "", err
+	}
+	return resp.Text(), resp.FinalURL, nil
+}
+
+/*
+执行一个自行构造的请求,返回完整的Response
+默认的Request Headers中,req未设置的部分会被自动补上,cookies自动继承
+
+例:
+ga := NewGather("chrome", false)
+req, _ := http.NewRequest("DELETE", "https://www.baidu.com/", nil)
+resp, err := ga.Do(req)
+*/
+func (g *GatherStruct) Do(req *http.Request) (*Response, error) {
+	return g.DoCtx(req.Context(), req)
+}
+
+//执行一个自行构造的请求,ctx取消或超时后立即中止抓取,其余与Do相同
+func (g *GatherStruct) DoCtx(ctx context.Context, req *http.Request) (*Response, error) {
+	req = withHeaderOrder(req.WithContext(ctx), g.headerOrder())
+	g.safeHeaders.Range(func(k, v interface{}) bool {
+		if req.Header.Get(k.(string)) == "" {
+			req.Header.Set(k.(string), v.(string))
+		}
+		return true
+	})
+	return g.request(req, nil)
+}
@@ -0,0 +1,274 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+/*
+重试策略,连接被重置,超时及429,502,503,504等临时性的失败会按指数退避自动重试
+Post,PostJson,PostBytes等带body的请求会在重试时重新发送完整的body,无法重新读取的body(如流式上传)不重试
+POST等非幂等的请求默认只在请求没有发出(连接失败,连接被拒绝)或服务器返回429,503时重试
+超时,连接被重置,502,504时服务器可能已经处理过这个请求,重试会导致重复提交,需要时设置RetryNonIdempotent
+请求带有Idempotency-Key或X-Idempotency-Key时与标准库一样视为幂等的请求
+
+例:
+ga := NewGather("chrome", false)
+ga.Retry = gather.DefaultRetryPolicy()
+ga.Retry.MaxAttempts = 5
+*/
+type RetryPolicy struct {
+	MaxAttempts int           //最多尝试次数,包括第一次,小于等于1表示不重试
+	MinBackoff  time.Duration //第一次重试前的等待时间,之后每次翻倍
+	MaxBackoff  time.Duration //每次等待时间的上限
+	Jitter      float64       //随机抖动的比例,0.2表示等待时间在计算值的上下20%内随机,避免大量请求同时重试
+	//是否遵从响应中的Retry-After,遵从时以Retry-After为准,不再按指数退避计算
+	RespectRetryAfter bool
+	//Retry-After超过此时间时不再重试,直接返回,为0表示不限制
+	MaxRetryAfter time.Duration
+	//需要重试的状态码,为nil时使用429,502,503,504
+	RetryStatusCodes []int
+	//自定义是否重试,设置后RetryStatusCodes,RetryNonIdempotent及默认的错误判断不再生效,resp与err只有一个不为nil
+	ShouldRetry func(resp *http.Response, err error) bool
+	//POST等非幂等的请求在已经发出后失败时也重试,与GET等幂等的请求相同
+	RetryNonIdempotent bool
+}
+
+//默认需要重试的状态码
+var defaultRetryStatusCodes = []int{429, 502, 503, 504}
+
+//非幂等的请求默认需要重试的状态码,服务器明确表示没有处理这个请求
+var nonIdempotentRetryStatusCodes = []int{429, 503}
+
+//常用的重试策略:最多尝试3次,等待时间从500毫秒开始翻倍,最长10秒,遵从不超过1分钟的Retry-After
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       3,
+		MinBackoff:        500 * time.Millisecond,
+		MaxBackoff:        10 * time.Second,
+		Jitter:            0.2,
+		RespectRetryAfter: true,
+		MaxRetryAfter:     time.Minute,
+	}
+}
+
+//判断本次的结果是否需要重试
+func (p *RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(resp, err)
+	}
+	idempotent := p.RetryNonIdempotent || isIdempotent(req)
+	if err != nil {
+		if idempotent {
+			return isTemporaryError(err)
+		}
+		return isNotSentError(err)
+	}
+	codes := p.RetryStatusCodes
+	if codes == nil {
+		codes = defaultRetryStatusCodes
+		if !idempotent {
+			codes = nonIdempotentRetryStatusCodes
+		}
+	}
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+//第attempt次失败后,到下一次重试之前需要等待的时间,ok为false表示Retry-After太长,不再重试
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) (wait time.Duration, ok bool) {
+	if p.RespectRetryAfter && resp != nil {
+		if d, exist := parseRetryAfter(resp.Header.Get("Retry-After")); exist {
+			if p.MaxRetryAfter > 0 && d > p.MaxRetryAfter {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+	wait = p.MinBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait = time.Duration(float64(wait) * (1 + p.Jitter*(rand.Float64()*2-1)))
+	}
+	return wait, true
+}
+
+//Retry-After可以是秒数,也可以是HTTP日期
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+//连接被重置,连接被拒绝,超时等临时性错误,调用方主动取消的不算
+func isTemporaryError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+//请求还没有发出的错误:连接目标主机或代理失败,连接被拒绝,重试不会导致重复提交
+func isNotSentError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+}
+
+//RFC 9110 9.2.2 幂等的方法,以及带有Idempotency-Key的请求
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	_, key := req.Header["Idempotency-Key"]
+	_, xKey := req.Header["X-Idempotency-Key"]
+	return key || xKey
+}
+
+//按重试策略发送请求,返回尚未读取内容的http.Response
+//需要重试时,放弃的响应内容会被读完并关闭,以便连接可以复用
+func (g *GatherStruct) send(req *http.Request) (*http.Response, error) {
+	return g.sendWith(g.Client, req)
+}
+
+//与send相同,但使用指定的client,用于下载等需要与g.Client不同超时设置的场合
+func (g *GatherStruct) sendWith(client *http.Client, req *http.Request) (*http.Response, error) {
+	if atomic.LoadInt32(&g.closed) != 0 {
+		closeRequestBody(req)
+		return nil, ErrGatherClosed
+	}
+	policy := g.Retry
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return g.sendOnce(client, req)
+	}
+	ctx := req.Context()
+	//http.Client会把cookies直接加到req.Header中,重试时须从原始的Header开始,否则cookies会重复
+	header := req.Header.Clone()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			r = req.Clone(ctx)
+			r.Header = header.Clone()
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		resp, err := g.sendOnce(client, r)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(req, resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		//body无法重新读取时不能重试
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+		wait, ok := policy.backoff(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.CopyN(ioutil.Discard, resp.Body, 64<<10)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+/*
+发送一次请求,设置了限速器时先等待限速
+代理的优先顺序:单次请求指定的代理,代理池选择的代理(并记录结果),SetProxy设置的代理,创建时指定的代理
+*/
+func (g *GatherStruct) sendOnce(client *http.Client, req *http.Request) (*http.Response, error) {
+	if limiter := g.Limiter; limiter != nil {
+		if err := limiter.Wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	if hasProxy(req.Context()) {
+		return client.Do(req)
+	}
+	pool := g.ProxyPool
+	if pool == nil {
+		if proxy, ok := g.proxy.Load().(*url.URL); ok {
+			req = req.WithContext(withProxy(req.Context(), proxy))
+		}
+		return client.Do(req)
+	}
+	proxy, err := pool.pick(req.URL.Host)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	ctx := req.Context()
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(withProxy(ctx, proxy.url)))
+	pool.report(ctx, proxy, resp, err, time.Since(start))
+	return resp, err
+}
+
+//给缓存池中的所有采集器设置同一个重试策略
+func (p *Pool) SetRetryPolicy(policy *RetryPolicy) {
+	for _, ga := range p.pool {
+		ga.Retry = policy
+	}
+}
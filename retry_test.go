@@ -0,0 +1,122 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryShouldRetry(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	proxyErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: syscall.ETIMEDOUT}}
+	readErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+	timeoutErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: context.DeadlineExceeded}
+	tests := []struct {
+		method        string
+		idempotentKey bool
+		nonIdempotent bool //RetryNonIdempotent
+		status        int
+		err           error
+		want          bool
+	}{
+		{"GET", false, false, 0, io.EOF, true},
+		{"GET", false, false, 0, readErr, true},
+		{"GET", false, false, 0, timeoutErr, true},
+		{"GET", false, false, 0, context.Canceled, false},
+		{"GET", false, false, 502, nil, true},
+		{"GET", false, false, 504, nil, true},
+		{"GET", false, false, 500, nil, false},
+		{"PUT", false, false, 0, readErr, true},
+		{"DELETE", false, false, 504, nil, true},
+		//请求已经发出,服务器可能已经处理过
+		{"POST", false, false, 0, io.EOF, false},
+		{"POST", false, false, 0, readErr, false},
+		{"POST", false, false, 0, timeoutErr, false},
+		{"POST", false, false, 502, nil, false},
+		{"POST", false, false, 504, nil, false},
+		{"PATCH", false, false, 0, readErr, false},
+		//请求没有发出,或服务器明确表示没有处理
+		{"POST", false, false, 0, dialErr, true},
+		{"POST", false, false, 0, proxyErr, true},
+		{"POST", false, false, 0, syscall.ECONNREFUSED, true},
+		{"POST", false, false, 429, nil, true},
+		{"POST", false, false, 503, nil, true},
+		//明确允许或带有Idempotency-Key时与幂等的请求相同
+		{"POST", false, true, 0, readErr, true},
+		{"POST", false, true, 504, nil, true},
+		{"POST", true, false, 0, timeoutErr, true},
+		{"POST", true, false, 502, nil, true},
+	}
+	for i, test := range tests {
+		req, _ := http.NewRequest(test.method, "http://127.0.0.1:1", nil)
+		if test.idempotentKey {
+			req.Header.Set("Idempotency-Key", "1")
+		}
+		p := DefaultRetryPolicy()
+		p.RetryNonIdempotent = test.nonIdempotent
+		var resp *http.Response
+		if test.err == nil {
+			resp = &http.Response{StatusCode: test.status, Header: http.Header{}}
+		}
+		if got := p.shouldRetry(req, resp, test.err); got != test.want {
+			t.Errorf("%d: %s status=%d err=%v 期望%v, 实际%v", i, test.method, test.status, test.err, test.want, got)
+		}
+	}
+}
+
+//服务器读完请求后不响应直接断开,POST默认不再重发
+func TestRetryPostNotResent(t *testing.T) {
+	var count int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		io.Copy(io.Discard, r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		method        string
+		headers       map[string]string
+		nonIdempotent bool
+		want          int64
+	}{
+		{"GET", nil, false, 3},
+		{"POST", nil, false, 1},
+		{"POST", nil, true, 3},
+		{"POST", map[string]string{"Idempotency-Key": "abc"}, false, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt64(&count, 0)
+		p := DefaultRetryPolicy()
+		p.MinBackoff, p.Jitter = time.Millisecond, 0
+		p.RetryNonIdempotent = test.nonIdempotent
+		ga := New(WithRetry(p))
+		var body io.Reader
+		if test.method == "POST" {
+			body = strings.NewReader("a=1")
+		}
+		if _, err := ga.Request(test.method, srv.URL, body, &RequestOptions{Headers: test.headers}); err == nil {
+			t.Fatalf("%s: 期望返回错误", test.method)
+		}
+		if n := atomic.LoadInt64(&count); n != test.want {
+			t.Fatalf("%s %v nonIdempotent=%v: 期望发送%d次, 实际%d次", test.method, test.headers, test.nonIdempotent, test.want, n)
+		}
+		ga.Close()
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/*
+是否为SOCKS代理,代理地址的协议决定代理类型:
+socks4:在本地解析域名,只支持IPv4,用户名作为USERID发送
+socks4a:由代理服务器解析域名
+socks5:在本地解析域名,支持用户名密码认证
+socks5h:由代理服务器解析域名,支持用户名密码认证
+*/
+func isSocksProxy(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "socks4", "socks4a", "socks5", "socks5h":
+		return true
+	}
+	return false
+}
+
+/*
+在已经连接到SOCKS代理的conn上,请求代理连接addr(host:port)
+ctx取消时立即中止握手
+*/
+func socksConnect(ctx context.Context, conn net.Conn, proxy *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("socks: 端口号有误: %s", addr)
+	}
+	stop := closeOnDone(ctx, conn)
+	switch strings.ToLower(proxy.Scheme) {
+	case "socks4", "socks4a":
+		err = socks4Connect(ctx, conn, proxy, host, port)
+	default:
+		err = socks5Connect(ctx, conn, proxy, host, port)
+	}
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+//在本地解析域名,ipv4为true时只要IPv4地址
+func socksResolve(ctx context.Context, host string, ipv4 bool) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ipv4 && ip.To4() == nil {
+			return nil, fmt.Errorf("socks4: 不支持IPv6地址%s", host)
+		}
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return a.IP, nil
+		}
+	}
+	if !ipv4 && len(addrs) > 0 {
+		return addrs[0].IP, nil
+	}
+	return nil, fmt.Errorf("socks: %s没有可用的IPv4地址", host)
+}
+
+func socks4Connect(ctx context.Context, conn net.Conn, proxy *url.URL, host string, port int) error {
+	req := []byte{4, 1, byte(port >> 8), byte(port)}
+	var domain string
+	if ip := net.ParseIP(host); ip == nil && strings.EqualFold(proxy.Scheme, "socks4a") {
+		//SOCKS4a:IP为0.0.0.x,域名放在USERID之后
+		req = append(req, 0, 0, 0, 1)
+		domain = host
+	} else {
+		ip, err := socksResolve(ctx, host, true)
+		if err != nil {
+			return err
+		}
+		req = append(req, ip.To4()...)
+	}
+	if proxy.User != nil {
+		req = append(req, proxy.User.Username()...)
+	}
+	req = append(req, 0)
+	if domain != "" {
+		req = append(req, domain...)
+		req = append(req, 0)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var resp [8]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 90 {
+		return fmt.Errorf("socks4: 代理服务器拒绝连接,状态码%d", resp[1])
+	}
+	return nil
+}
+
+//SOCKS5的认证方式
+const (
+	socks5NoAuth       = 0x00
+	socks5UserPassAuth = 0x02
+	socks5NoAcceptable = 0xff
+)
+
+//SOCKS5 CONNECT失败时的原因,RFC 1928
+var socks5Errors = []string{
+	"",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+func socks5Connect(ctx context.Context, conn net.Conn, proxy *url.URL, host string, port int) error {
+	methods := []byte{socks5NoAuth}
+	if proxy.User != nil {
+		methods = append(methods, socks5UserPassAuth)
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return fmt.Errorf("socks5: 代理服务器的版本号有误: %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPassAuth:
+		if proxy.User == nil {
+			return errors.New("socks5: 代理服务器要求用户名密码")
+		}
+		if err := socks5Auth(conn, proxy.User); err != nil {
+			return err
+		}
+	case socks5NoAcceptable:
+		return errors.New("socks5: 代理服务器不接受任何认证方式")
+	default:
+		return fmt.Errorf("socks5: 不支持的认证方式%d", reply[1])
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil && strings.EqualFold(proxy.Scheme, "socks5h") {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: 域名太长: %s", host)
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	} else {
+		ip, err := socksResolve(ctx, host, false)
+		if err != nil {
+			return err
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, 1)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, 4)
+			req = append(req, ip.To16()...)
+		}
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	//VER REP RSV ATYP,之后是代理服务器绑定的地址,读取后丢弃
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		if int(head[1]) < len(socks5Errors) {
+			return fmt.Errorf("socks5: %s", socks5Errors[head[1]])
+		}
+		return fmt.Errorf("socks5: 代理服务器拒绝连接,状态码%d", head[1])
+	}
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("socks5: 未知的地址类型%d", head[3])
+	}
+	_, err := io.CopyN(io.Discard, conn, int64(skip+2))
+	return err
+}
+
+//用户名密码认证,RFC 1929
+func socks5Auth(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("socks5: 用户名或密码太长")
+	}
+	req := []byte{1, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errors.New("socks5: 用户名或密码错误")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+/*
+TLS设置,默认与原来一样不校验服务器证书,以便抓取证书过期或自签名的网站
+需要校验证书,信任私有CA,或对接要求双向认证(mutual TLS)的合作方接口时使用
+
+例:
+ga := gather.New(gather.WithTLS(&gather.TLSOptions{
+Verify:      true,
+RootCAFiles: []string{"/etc/partner/ca.pem"},
+ClientCerts: []gather.ClientCert{{CertFile: "client.pem", KeyFile: "client.key"}},
+MinVersion:  tls.VersionTLS12,
+}))
+*/
+type TLSOptions struct {
+	Verify      bool         //是否校验服务器证书,默认不校验
+	RootCAFiles []string     //PEM格式的CA证书文件,在系统CA的基础上额外信任,只在Verify为true时有意义
+	RootCAPEM   [][]byte     //PEM格式的CA证书内容,与RootCAFiles作用相同
+	ClientCerts []ClientCert //双向认证时向服务器出示的客户端证书
+	MinVersion  uint16       //最低TLS版本,如tls.VersionTLS12,为0时使用标准库的默认值
+	MaxVersion  uint16       //最高TLS版本,如tls.VersionTLS13,为0时使用标准库的默认值
+	ServerName  string       //覆盖握手时发送的SNI及校验证书时使用的主机名,为空时使用请求的主机名
+}
+
+//PEM格式的客户端证书及私钥文件
+type ClientCert struct {
+	CertFile string
+	KeyFile  string
+}
+
+//按TLSOptions生成tls.Config,base不为nil时在它的副本上修改
+//证书文件无法读取或解析时返回错误
+func (o *TLSOptions) Config(base *tls.Config) (*tls.Config, error) {
+	var cfg *tls.Config
+	if base != nil {
+		cfg = base.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	cfg.InsecureSkipVerify = !o.Verify
+	if len(o.RootCAFiles) > 0 || len(o.RootCAPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pems := append([][]byte(nil), o.RootCAPEM...)
+		for _, file := range o.RootCAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("读取CA证书失败: %w", err)
+			}
+			pems = append(pems, pem)
+		}
+		for i, pem := range pems {
+			if !pool.AppendCertsFromPEM(pem) {
+				if i >= len(o.RootCAPEM) {
+					return nil, fmt.Errorf("CA证书%s中没有有效的PEM证书", o.RootCAFiles[i-len(o.RootCAPEM)])
+				}
+				return nil, fmt.Errorf("第%d个RootCAPEM中没有有效的PEM证书", i+1)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	for _, c := range o.ClientCerts {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书%s失败: %w", c.CertFile, err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	if o.MinVersion != 0 {
+		cfg.MinVersion = o.MinVersion
+	}
+	if o.MaxVersion != 0 {
+		cfg.MaxVersion = o.MaxVersion
+	}
+	if o.MinVersion != 0 && o.MaxVersion != 0 && o.MinVersion > o.MaxVersion {
+		return nil, fmt.Errorf("TLS最低版本%#x高于最高版本%#x", o.MinVersion, o.MaxVersion)
+	}
+	if o.ServerName != "" {
+		cfg.ServerName = o.ServerName
+	}
+	return cfg, nil
+}
+
+//无法创建传输层时使用,每次请求都返回创建时的错误,New不返回错误,只能在请求时报告
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
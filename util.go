@@ -9,12 +9,13 @@ package gather
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"sort"
-	"strconv"
+	"time"
 )
 
 //解压GZIP文件
@@ -33,76 +34,118 @@ func Ungzip(data []byte) (string, error) {
 }
 
 //一个新的request对象
-func (g *GatherStruct) newHttpRequest(method, URL, refererURL, cookies string, body io.Reader) (*http.Request, error) {
+//Request Headers按以下顺序设置,后设置的覆盖先设置的:
+//defaultContentType(有body时),GatherStruct中的默认Request Headers,opts中的ContentType,Headers,Referer及Cookies
+//opts中的内容只对本次请求生效,不会写回GatherStruct
+//最后按Referer修正Profile中的Sec-Fetch-Site
+func (g *GatherStruct) newHttpRequest(ctx context.Context, method, URL string, body io.Reader, defaultContentType string, opts *RequestOptions) (*http.Request, error) {
 	defer func() {
 		if err := recover(); err != nil {
 			panic(fmt.Sprintf("采集器可能未成功初始化,请先使用NewGather或NewGatherUtil或NewGatherProxy函数初始化再使用,具体错误信息:%v", err))
 		}
 	}()
 
-	req, err := http.NewRequest(method, URL, body)
+	req, err := http.NewRequestWithContext(ctx, method, URL, body)
 	if err != nil {
 		return req, err
 	}
-	// Referer
-	if refererURL != "" {
-		g.safeHeaders.Store("Referer", refererURL)
+	if body != nil && defaultContentType != "" {
+		req.Header.Set("Content-Type", defaultContentType)
 	}
-	//cookies
-	if cookies != "" {
-		g.safeHeaders.Store("Cookie", cookies)
-	}
-	//把header 按顺序添加进去
-	type headerStruct struct {
-		k string
-		v string
-	}
-	var h []headerStruct
-
 	g.safeHeaders.Range(func(k, v interface{}) bool {
-		h = append(h, headerStruct{k.(string), v.(string)})
+		req.Header.Set(k.(string), v.(string))
 		return true
 	})
-
-	sort.Slice(h, func(i, j int) bool {
-		return h[i].k <= h[j].k
-	})
-	for _, v := range h {
-		req.Header.Set(v.k, v.v)
+	if opts != nil {
+		opts.apply(req)
+		if proxy, err := opts.proxy(); err != nil {
+			return nil, err
+		} else if proxy != nil {
+			req = req.WithContext(withProxy(req.Context(), proxy))
+		}
 	}
-	return req, nil
+	fixSecFetchSite(req)
+	//Header在线路上的顺序由传输层按HeaderOrder决定
+	return withHeaderOrder(req, g.headerOrder()), nil
 }
 
-//最终抓取HTML
-func (g *GatherStruct) request(req *http.Request) (html, redirectURL string, err error) {
-	resp, err := g.Client.Do(req)
+//最终抓取,返回完整的Response,状态码不被认为是成功时同时返回Response与*StatusError
+//opts不为nil时,其中的SuccessStatusCodes优先于GatherStruct中的设置
+func (g *GatherStruct) request(req *http.Request, opts *RequestOptions) (*Response, error) {
+	start := time.Now()
+	var policy *RedirectPolicy
+	if opts != nil {
+		policy = opts.Redirect
+	}
+	req, redirect := withRedirectState(req, policy)
+	resp, err := g.send(req)
 
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
-	//注意200,202都表示成功
-	if !(resp.StatusCode == 200 || resp.StatusCode == 202) {
-		return "", "", fmt.Errorf("http状态码:" + strconv.Itoa(resp.StatusCode))
-	}
-	var data []byte
-	// if g.HTMLShouldConvertToUTF8 {
-	// 	//判断网页是什么编码
-	// 	e := determineEncoding(resp.Body)
-	// 	//转换为utf8
-	// 	utf8Reader := transform.NewReader(resp.Body, e.NewDecoder())
-	// 	data, err = ioutil.ReadAll(utf8Reader)
-	// } else {
-	data, err = ioutil.ReadAll(resp.Body)
-	//}
-
+	//响应内容的长度在解压前后都受MaxBodySize限制,以免压缩后很小的内容解压后耗尽内存
+	maxSize, truncate := g.bodyLimit(opts)
+	if maxSize > 0 {
+		resp.Body = &limitedBody{&sizeLimitReader{r: resp.Body, remaining: maxSize, err: ErrBodyTooLarge}, resp.Body}
+	}
+	//按Content-Encoding自动解压
+	body, err := g.decodeBody(resp)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+	defer body.Close()
+	var reader io.Reader = body
+	if maxSize > 0 {
+		reader = &sizeLimitReader{r: body, remaining: maxSize, err: ErrBodyTooLarge}
 	}
-	//自动处理GZIP压缩的情况
-	html, err = Ungzip(data)
+	data, err := ioutil.ReadAll(reader)
+	truncated := false
 	if err != nil {
-		html = string(data)
+		if !errors.Is(err, ErrBodyTooLarge) {
+			return nil, err
+		}
+		if !truncate {
+			return nil, fmt.Errorf("响应内容超过%d字节: %w", maxSize, err)
+		}
+		truncated = true
+	}
+	r := &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Proto:      resp.Proto,
+		Header:     resp.Header,
+		Body:       data,
+		Truncated:  truncated,
+		FinalURL:   resp.Request.URL.String(),
+		Hops:       redirectHops(resp.Request),
+		SetCookies: resp.Cookies(),
+		Elapsed:    time.Since(start),
+		Request:    req,
+	}
+	for _, hop := range r.Hops {
+		r.Redirects = append(r.Redirects, hop.URL)
+	}
+	//只对文本类的内容判断编码,编码有声明或能从内容中判断出来时转换为utf8,Body中保留原始内容
+	if contentType := resp.Header.Get("Content-Type"); isTextContentType(contentType) {
+		r.Charset = DetectCharset(data, contentType)
+	}
+	if g.HTMLShouldConvertToUTF8 && r.Charset != "" && r.Charset != "utf-8" {
+		if text, err := ConvertToUTF8(data, r.Charset); err == nil {
+			r.text = text
+		}
+	}
+	if g.Client.Jar != nil {
+		r.Cookies = g.Client.Jar.Cookies(resp.Request.URL)
+	}
+	successCodes := g.SuccessStatusCodes
+	if opts != nil && opts.SuccessStatusCodes != nil {
+		successCodes = opts.SuccessStatusCodes
+	}
+	//因跳转策略停止跳转时,返回的3xx是调用方想要的结果
+	stoppedRedirect := redirect.stopped && resp.StatusCode >= 300 && resp.StatusCode < 400
+	if !stoppedRedirect && !isSuccessStatus(resp.StatusCode, successCodes) {
+		return r, newStatusError(r)
 	}
-	return html, resp.Request.URL.String(), nil
+	return r, nil
 }
@@ -3,65 +3,380 @@
 // This Source Code Form is subject to the terms of the MIT License.
 // If a copy of the MIT was not distributed with this file,
 // You can obtain one at https://github.com/yudeguang/gather.
+//
+// The cookie matching logic (domain, path and expiry handling) is derived
+// from net/http/cookiejar of the Go standard library:
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the licence-go file.
 //模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
 package gather
 
 import (
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+//会话cookie(没有Expires及Max-Age)的过期时间,相当于永不过期,进程结束即丢弃
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+var (
+	errIllegalDomain   = errors.New("cookiejar: illegal cookie domain attribute")
+	errMalformedDomain = errors.New("cookiejar: malformed cookie domain attribute")
+	errNoHostname      = errors.New("cookiejar: no host name available (IP only)")
 )
 
-//cookie的保存对象
-type webCookieJar struct {
+//cookie的保存对象,按RFC 6265处理Domain,Path,Secure,Expires及Max-Age
+//cookies按站点的eTLD+1分组保存,例如www.example.com与img.example.com共用example.com这一组
+type WebCookieJar struct {
 	lk            sync.Mutex
-	cookies       map[string][]*http.Cookie
+	cookies       map[string]map[string]cookieEntry //eTLD+1 -> cookie的id -> cookie
 	cookieLogOpen bool
+	logger        *log.Logger //cookie变更日志的输出位置,为nil时使用log包默认的输出
+	nextSeqNum    uint64      //创建时间相同时用于排序
+}
+
+//jar中保存的一条cookie
+type cookieEntry struct {
+	Name       string
+	Value      string
+	Domain     string
+	Path       string
+	SameSite   http.SameSite
+	Secure     bool
+	HttpOnly   bool
+	Persistent bool //是否设置了Expires或Max-Age,会话cookie为false
+	HostOnly   bool //没有Domain属性时只发给设置它的主机,不发给子域名
+	Expires    time.Time
+	Creation   time.Time
+	LastAccess time.Time
+	seqNum     uint64
+}
+
+//同一个Domain,Path,Name的cookie视为同一条,后设置的覆盖先设置的
+func (e *cookieEntry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+//判断是否应该随请求发送这条cookie
+func (e *cookieEntry) shouldSend(https bool, host, path string) bool {
+	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.Secure)
+}
+
+//RFC 6265 5.1.3
+func (e *cookieEntry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+	return !e.HostOnly && hasDotSuffix(host, e.Domain)
+}
+
+//RFC 6265 5.1.4
+func (e *cookieEntry) pathMatch(requestPath string) bool {
+	if requestPath == e.Path {
+		return true
+	}
+	if strings.HasPrefix(requestPath, e.Path) {
+		if e.Path[len(e.Path)-1] == '/' {
+			return true
+		} else if requestPath[len(e.Path)] == '/' {
+			return true
+		}
+	}
+	return false
 }
 
-func newWebCookieJar(isCookieLogOpen bool) *webCookieJar {
-	jar := new(webCookieJar)
+//新建一个cookie保存对象,可通过WithJar让多个GatherStruct共用
+//isCookieLogOpen:Cookie变更时是否打印
+func NewWebCookieJar(isCookieLogOpen bool) *WebCookieJar {
+	jar := new(WebCookieJar)
 	jar.cookieLogOpen = isCookieLogOpen
-	jar.cookies = make(map[string][]*http.Cookie)
+	jar.cookies = make(map[string]map[string]cookieEntry)
 	return jar
 }
-func (j *webCookieJar) SetCookies(u *url.URL, newCookies []*http.Cookie) {
+
+//设置cookie变更日志的输出位置,为nil时使用log包默认的输出
+func (j *WebCookieJar) SetLogger(logger *log.Logger) {
 	j.lk.Lock()
 	defer j.lk.Unlock()
-	//如果原来有了就覆盖,根据host和Path判断
-	oldCookies := j.cookies[u.Host]
-	if j.cookieLogOpen {
-		log.Println("COOKIE变更:", u.String())
-	}
-	for newIndex := 0; newIndex < len(newCookies); newIndex++ {
-		isFound := false
-		for oldIndex := 0; oldIndex < len(oldCookies); oldIndex++ {
-			if oldCookies[oldIndex].Name == newCookies[newIndex].Name &&
-				oldCookies[oldIndex].Path == newCookies[newIndex].Path {
-				//原来有的，就直接替换就可以
-				oldCookies[oldIndex] = newCookies[newIndex]
-				if j.cookieLogOpen {
-					log.Println("替换cookie:", newCookies[newIndex].String())
-				}
-				isFound = true
-				break
-			}
+	j.logger = logger
+}
+
+//打印cookie变更日志,调用时须已持有j.lk
+func (j *WebCookieJar) println(v ...interface{}) {
+	if !j.cookieLogOpen {
+		return
+	}
+	if j.logger != nil {
+		j.logger.Println(v...)
+	} else {
+		log.Println(v...)
+	}
+}
+
+func (j *WebCookieJar) SetCookies(u *url.URL, newCookies []*http.Cookie) {
+	if len(newCookies) == 0 || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	key := jarKey(host)
+	defPath := defaultPath(u.Path)
+	now := time.Now()
+
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	j.println("COOKIE变更:", u.String())
+	submap := j.cookies[key]
+	for _, cookie := range newCookies {
+		e, remove, err := j.newEntry(cookie, now, defPath, host)
+		if err != nil {
+			j.println("忽略cookie:", cookie.String(), err)
+			continue
 		}
-		if !isFound {
-			oldCookies = append(oldCookies, newCookies[newIndex])
-			if j.cookieLogOpen {
-				log.Println("添加cookie:", newCookies[newIndex].String())
+		id := e.id()
+		old, exist := submap[id]
+		//Max-Age<0或Expires已过期,表示服务器要求删除这条cookie
+		if remove {
+			if exist {
+				delete(submap, id)
+				j.println("删除cookie:", cookie.String())
 			}
+			continue
+		}
+		if submap == nil {
+			submap = make(map[string]cookieEntry)
 		}
+		if exist {
+			//原来有的，就直接替换就可以,但保留最初的创建时间以维持发送顺序
+			e.Creation = old.Creation
+			e.seqNum = old.seqNum
+			j.println("替换cookie:", cookie.String())
+		} else {
+			e.Creation = now
+			e.seqNum = j.nextSeqNum
+			j.nextSeqNum++
+			j.println("添加cookie:", cookie.String())
+		}
+		e.LastAccess = now
+		submap[id] = e
+	}
+	if len(submap) == 0 {
+		delete(j.cookies, key)
+	} else {
+		j.cookies[key] = submap
 	}
-	j.cookies[u.Host] = oldCookies
 }
-func (j *webCookieJar) Cookies(u *url.URL) []*http.Cookie {
-	cookies := j.cookies[u.Host]
-	/*log.Println("URL:", u.String())
-	for i, c := range cookies {
-		log.Println("cookie:", i, c.String())
-	}*/
+
+func (j *WebCookieJar) Cookies(u *url.URL) (cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return cookies
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return cookies
+	}
+	key := jarKey(host)
+
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	submap := j.cookies[key]
+	if submap == nil {
+		return cookies
+	}
+	https := u.Scheme == "https"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+	var selected []cookieEntry
+	for id, e := range submap {
+		//过期的cookie直接删除,不再发送
+		if e.Persistent && !e.Expires.After(now) {
+			delete(submap, id)
+			continue
+		}
+		if !e.shouldSend(https, host, path) {
+			continue
+		}
+		e.LastAccess = now
+		submap[id] = e
+		selected = append(selected, e)
+	}
+	if len(submap) == 0 {
+		delete(j.cookies, key)
+	}
+	//RFC 6265 5.4 路径长的排在前面,路径相同时先创建的排在前面
+	sort.Slice(selected, func(i, k int) bool {
+		s := selected
+		if len(s[i].Path) != len(s[k].Path) {
+			return len(s[i].Path) > len(s[k].Path)
+		}
+		if !s[i].Creation.Equal(s[k].Creation) {
+			return s[i].Creation.Before(s[k].Creation)
+		}
+		return s[i].seqNum < s[k].seqNum
+	})
+	for _, e := range selected {
+		cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
 	return cookies
 }
+
+//由服务器下发的cookie生成jar中保存的cookie,remove为true表示这条cookie应被删除
+func (j *WebCookieJar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e cookieEntry, remove bool, err error) {
+	e.Name = c.Name
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = c.Path
+	}
+	e.Domain, e.HostOnly, err = domainAndType(host, c.Domain)
+	if err != nil {
+		return e, false, err
+	}
+	//Max-Age优先于Expires
+	if c.MaxAge < 0 {
+		return e, true, nil
+	} else if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		e.Persistent = true
+	} else {
+		if c.Expires.IsZero() {
+			e.Expires = endOfTime
+			e.Persistent = false
+		} else {
+			if !c.Expires.After(now) {
+				return e, true, nil
+			}
+			e.Expires = c.Expires
+			e.Persistent = true
+		}
+	}
+	e.Value = c.Value
+	e.Secure = c.Secure
+	e.HttpOnly = c.HttpOnly
+	e.SameSite = c.SameSite
+	return e, false, nil
+}
+
+//RFC 6265 5.3 第4至6步,判断Domain属性是否合法,并返回cookie实际所属的域名
+//Domain为公共后缀(如com,com.cn)时,除非与主机名完全相同,否则拒绝,以免一个站点给所有站点设置cookie
+func domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		//没有Domain属性,只发给当前主机
+		return host, true, nil
+	}
+	if isIP(host) {
+		if host != domain {
+			return "", false, errIllegalDomain
+		}
+		return host, true, nil
+	}
+	if domain[0] == '.' {
+		domain = domain[1:]
+	}
+	if len(domain) == 0 || domain[0] == '.' {
+		return "", false, errMalformedDomain
+	}
+	domain, err := toASCII(strings.ToLower(domain))
+	if err != nil {
+		return "", false, errMalformedDomain
+	}
+	if domain[len(domain)-1] == '.' {
+		return "", false, errMalformedDomain
+	}
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		if host != domain {
+			return "", false, errIllegalDomain
+		}
+		return host, true, nil
+	}
+	if host != domain && !hasDotSuffix(host, domain) {
+		return "", false, errIllegalDomain
+	}
+	return domain, false, nil
+}
+
+//jar中的分组依据,即eTLD+1,IP地址及无法判断的直接用主机名
+func jarKey(host string) string {
+	if isIP(host) {
+		return host
+	}
+	key, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return key
+}
+
+//去掉端口号及末尾的点,并转换为小写的ASCII形式
+func canonicalHost(host string) (string, error) {
+	if hasPort(host) {
+		h, _, err := net.SplitHostPort(host)
+		if err != nil {
+			return "", err
+		}
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", errNoHostname
+	}
+	return toASCII(strings.ToLower(host))
+}
+
+func hasPort(host string) bool {
+	colons := strings.Count(host, ":")
+	if colons == 0 {
+		return false
+	}
+	if colons == 1 {
+		return true
+	}
+	//IPv6地址带端口时形如[::1]:80
+	return host[0] == '[' && strings.Contains(host, "]:")
+}
+
+func isIP(host string) bool {
+	return net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+//中文域名等转换为punycode
+func toASCII(s string) (string, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return idna.Lookup.ToASCII(s)
+		}
+	}
+	return s, nil
+}
+
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && s[len(s)-len(suffix)-1] == '.' && s[len(s)-len(suffix):] == suffix
+}
+
+//RFC 6265 5.1.4 由请求的路径得到默认的cookie路径
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
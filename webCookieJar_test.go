@@ -0,0 +1,200 @@
+// Copyright 2020 ratelimit Author(https://github.com/yudeguang/gather). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://github.com/yudeguang/gather.
+//模拟浏览器进行数据采集包,可较方便的定义http头，同时全自动化处理cookies
+package gather
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//按顺序逐条下发Set-Cookie,每条单独调用一次SetCookies
+func setCookies(j *WebCookieJar, rawURL string, setCookies ...string) {
+	u, _ := url.Parse(rawURL)
+	for _, sc := range setCookies {
+		resp := http.Response{Header: http.Header{"Set-Cookie": {sc}}}
+		j.SetCookies(u, resp.Cookies())
+	}
+}
+
+//请求rawURL时发送的cookie,格式为"a=1 b=2",按发送顺序
+func sentCookies(j *WebCookieJar, rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	var s []string
+	for _, c := range j.Cookies(u) {
+		s = append(s, c.Name+"="+c.Value)
+	}
+	return strings.Join(s, " ")
+}
+
+type jarQuery struct {
+	url  string
+	want string
+}
+
+var pastDate = time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+var futureDate = time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+var jarTests = []struct {
+	name       string
+	setURL     string
+	setCookies []string
+	queries    []jarQuery
+}{
+	{
+		"Domain=.example.com由子域名设置",
+		"http://www.example.com/",
+		[]string{"a=1; Domain=.example.com"},
+		[]jarQuery{
+			{"http://www.example.com/", "a=1"},
+			{"http://example.com/", "a=1"},
+			{"http://img.example.com/", "a=1"},
+			{"http://a.b.example.com/", "a=1"},
+			{"http://example.org/", ""},
+			{"http://notexample.com/", ""},
+		},
+	},
+	{
+		"没有Domain时只发给当前主机",
+		"http://www.example.com/",
+		[]string{"a=1"},
+		[]jarQuery{
+			{"http://www.example.com/", "a=1"},
+			{"http://WWW.Example.COM:8080/", "a=1"},
+			{"http://example.com/", ""},
+			{"http://sub.www.example.com/", ""},
+		},
+	},
+	{
+		"Domain与主机无关时拒绝",
+		"http://www.example.com/",
+		[]string{"a=1; Domain=example.org", "b=2; Domain=ww.example.com"},
+		[]jarQuery{
+			{"http://www.example.com/", ""},
+			{"http://example.org/", ""},
+		},
+	},
+	{
+		"Domain为公共后缀时拒绝",
+		"http://www.example.co.uk/",
+		[]string{"a=1; Domain=co.uk", "b=2; Domain=.uk", "c=3; Domain=example.co.uk"},
+		[]jarQuery{
+			{"http://www.example.co.uk/", "c=3"},
+			{"http://other.co.uk/", ""},
+			{"http://img.example.co.uk/", "c=3"},
+		},
+	},
+	{
+		"主机名本身是公共后缀时作为host-only保存",
+		"http://co.uk/",
+		[]string{"a=1; Domain=co.uk"},
+		[]jarQuery{
+			{"http://co.uk/", "a=1"},
+			{"http://www.co.uk/", ""},
+		},
+	},
+	{
+		"Path按前缀匹配,路径长的排在前面",
+		"http://www.example.com/a/b",
+		[]string{"a=1; Path=/a", "b=2; Path=/a/", "c=3", "d=4; Path=/"},
+		[]jarQuery{
+			{"http://www.example.com/a/b", "b=2 a=1 c=3 d=4"},
+			{"http://www.example.com/a/", "b=2 a=1 c=3 d=4"},
+			{"http://www.example.com/a", "a=1 c=3 d=4"},
+			{"http://www.example.com/ab", "d=4"},
+			{"http://www.example.com/", "d=4"},
+			{"http://www.example.com", "d=4"},
+		},
+	},
+	{
+		"Secure的cookie不通过http发送",
+		"https://www.example.com/",
+		[]string{"s=1; Secure", "p=2"},
+		[]jarQuery{
+			{"https://www.example.com/", "s=1 p=2"},
+			{"http://www.example.com/", "p=2"},
+		},
+	},
+	{
+		"Max-Age=0删除已有的cookie",
+		"http://www.example.com/",
+		[]string{"a=1", "b=2", "a=1; Max-Age=0"},
+		[]jarQuery{
+			{"http://www.example.com/", "b=2"},
+		},
+	},
+	{
+		"Max-Age优先于Expires",
+		"http://www.example.com/",
+		[]string{"a=1; Max-Age=3600; Expires=" + pastDate, "b=2; Max-Age=0; Expires=" + futureDate},
+		[]jarQuery{
+			{"http://www.example.com/", "a=1"},
+		},
+	},
+	{
+		"Expires已过期时删除已有的cookie,未过期时保存",
+		"http://www.example.com/",
+		[]string{"a=1", "b=2", "a=1; Expires=" + pastDate, "c=3; Expires=" + futureDate},
+		[]jarQuery{
+			{"http://www.example.com/", "b=2 c=3"},
+		},
+	},
+	{
+		"同一Domain,Path,Name的cookie后设置的覆盖先设置的",
+		"http://www.example.com/",
+		[]string{"a=1", "a=2", "a=3; Domain=example.com"},
+		[]jarQuery{
+			{"http://www.example.com/", "a=2 a=3"},
+			{"http://img.example.com/", "a=3"},
+		},
+	},
+	{
+		"IP地址只接受与之相同的Domain",
+		"http://127.0.0.1/",
+		[]string{"a=1", "b=2; Domain=127.0.0.1", "c=3; Domain=127.0.0.2"},
+		[]jarQuery{
+			{"http://127.0.0.1/", "a=1 b=2"},
+		},
+	},
+}
+
+func TestWebCookieJar(t *testing.T) {
+	for _, test := range jarTests {
+		j := NewWebCookieJar(false)
+		setCookies(j, test.setURL, test.setCookies...)
+		for _, q := range test.queries {
+			if got := sentCookies(j, q.url); got != q.want {
+				t.Errorf("%s: 请求%s时期望发送%q, 实际%q", test.name, q.url, q.want, got)
+			}
+		}
+	}
+}
+
+//保存期间过期的cookie不再发送,并从jar中删除
+func TestWebCookieJarExpiry(t *testing.T) {
+	j := NewWebCookieJar(false)
+	setCookies(j, "http://www.example.com/", "a=1; Max-Age=3600", "b=2")
+	if got := sentCookies(j, "http://www.example.com/"); got != "a=1 b=2" {
+		t.Fatal(got)
+	}
+	j.lk.Lock()
+	for id, e := range j.cookies["example.com"] {
+		if e.Name == "a" {
+			e.Expires = time.Now().Add(-time.Second)
+			j.cookies["example.com"][id] = e
+		}
+	}
+	j.lk.Unlock()
+	if got := sentCookies(j, "http://www.example.com/"); got != "b=2" {
+		t.Fatalf("过期的cookie仍被发送: %q", got)
+	}
+	if n := len(j.cookies["example.com"]); n != 1 {
+		t.Fatalf("过期的cookie未从jar中删除, 剩余%d条", n)
+	}
+}